@@ -0,0 +1,318 @@
+package client
+
+import (
+	"crypto/md5" //nolint: gosec
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	digestAlgorithmMD5        = "MD5"
+	digestAlgorithmMD5Sess    = "MD5-SESS"
+	digestAlgorithmSHA256     = "SHA-256"
+	digestAlgorithmSHA256Sess = "SHA-256-SESS"
+
+	digestQopAuth = "auth"
+)
+
+// authenticator authorizes outbounding requests and answers the authentication challenges
+// returned by servers
+type authenticator interface {
+	// authorize sets the credentials of req before it is sent
+	authorize(req *http.Request) error
+	// challenge inspects a 401 response of req and reports whether req should be sent again
+	challenge(req *http.Request, resp *http.Response) (bool, error)
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func (a *basicAuth) authorize(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+func (a *basicAuth) challenge(*http.Request, *http.Response) (bool, error) {
+	// basic credentials are always sent preemptively, there is nothing else to answer with
+	return false, nil
+}
+
+// digestChallenge is the state of a Digest protection space learnt from a server challenge
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	nc        uint32
+	// algorithmToken is the algorithm as sent by the server, which is echoed as is
+	algorithmToken string
+}
+
+// digestAuth implements RFC 7616 Digest access authentication, it remembers the last challenge
+// of each host so that subsequent requests are authorized preemptively with an increasing nonce count
+type digestAuth struct {
+	username string
+	password string
+
+	mu         sync.Mutex
+	challenges map[string]*digestChallenge
+}
+
+func newDigestAuth(username, password string) *digestAuth {
+	return &digestAuth{
+		username:   username,
+		password:   password,
+		challenges: make(map[string]*digestChallenge),
+	}
+}
+
+func (a *digestAuth) authorize(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	dc, ok := a.challenges[req.URL.Host]
+	if !ok {
+		return nil
+	}
+
+	dc.nc++
+
+	authorization, err := a.authorization(req, dc)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", authorization)
+
+	return nil
+}
+
+func (a *digestAuth) challenge(req *http.Request, resp *http.Response) (bool, error) {
+	params, ok := selectDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return false, nil
+	}
+
+	algorithmToken := params["algorithm"]
+	if algorithmToken == "" {
+		algorithmToken = digestAlgorithmMD5
+	}
+	algorithm := strings.ToUpper(algorithmToken)
+
+	switch algorithm {
+	case digestAlgorithmMD5, digestAlgorithmMD5Sess, digestAlgorithmSHA256, digestAlgorithmSHA256Sess:
+	default:
+		return false, errors.Errorf("unsupported digest algorithm %q", params["algorithm"])
+	}
+
+	qop := ""
+	if qops, ok := params["qop"]; ok {
+		for _, q := range strings.Split(qops, ",") {
+			if strings.TrimSpace(q) == digestQopAuth {
+				qop = digestQopAuth
+			}
+		}
+
+		if qop == "" {
+			return false, errors.Errorf("unsupported digest qop %q", qops)
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// a server rejecting credentials computed with its current nonce means the credentials are wrong,
+	// unless it flags the nonce as stale, in which case we answer the new nonce
+	if prev, ok := a.challenges[req.URL.Host]; ok && req.Header.Get("Authorization") != "" &&
+		prev.nonce == params["nonce"] && !strings.EqualFold(params["stale"], "true") {
+		delete(a.challenges, req.URL.Host)
+		return false, nil
+	}
+
+	a.challenges[req.URL.Host] = &digestChallenge{
+		realm:          params["realm"],
+		nonce:          params["nonce"],
+		opaque:         params["opaque"],
+		algorithm:      algorithm,
+		algorithmToken: algorithmToken,
+		qop:            qop,
+	}
+
+	return true, nil
+}
+
+func (a *digestAuth) authorization(req *http.Request, dc *digestChallenge) (string, error) {
+	var newHash func() hash.Hash
+	if strings.HasPrefix(dc.algorithm, digestAlgorithmSHA256) {
+		newHash = sha256.New
+	} else {
+		newHash = md5.New
+	}
+
+	h := func(s string) string {
+		hh := newHash()
+		_, _ = hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	cnonce, err := newCnonce()
+	if err != nil {
+		return "", errors.Wrap(err, "error generating digest cnonce")
+	}
+
+	nc := fmt.Sprintf("%08x", dc.nc)
+	uri := req.URL.RequestURI()
+
+	ha1 := h(fmt.Sprintf("%s:%s:%s", a.username, dc.realm, a.password))
+	if strings.HasSuffix(dc.algorithm, "-SESS") {
+		ha1 = h(fmt.Sprintf("%s:%s:%s", ha1, dc.nonce, cnonce))
+	}
+	ha2 := h(fmt.Sprintf("%s:%s", req.Method, uri))
+
+	var response string
+	if dc.qop == "" {
+		response = h(fmt.Sprintf("%s:%s:%s", ha1, dc.nonce, ha2))
+	} else {
+		response = h(fmt.Sprintf("%s:%s:%s:%s:%s:%s", ha1, dc.nonce, nc, cnonce, dc.qop, ha2))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `Digest username=%s, realm=%s, nonce=%s, uri=%s, algorithm=%s, response=%s`,
+		quote(a.username), quote(dc.realm), quote(dc.nonce), quote(uri), dc.algorithmToken, quote(response))
+	if dc.qop != "" {
+		fmt.Fprintf(&b, `, qop=%s, nc=%s, cnonce=%s`, dc.qop, nc, quote(cnonce))
+	}
+	if dc.opaque != "" {
+		fmt.Fprintf(&b, `, opaque=%s`, quote(dc.opaque))
+	}
+
+	return b.String(), nil
+}
+
+func newCnonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// selectDigestChallenge picks the strongest Digest challenge among the WWW-Authenticate header values
+func selectDigestChallenge(values []string) (map[string]string, bool) {
+	var selected map[string]string
+
+	for _, v := range values {
+		for _, c := range splitChallenges(v) {
+			scheme, rest, _ := strings.Cut(strings.TrimSpace(c), " ")
+			if !strings.EqualFold(scheme, "Digest") {
+				continue
+			}
+
+			params := parseAuthParams(rest)
+			if selected == nil || strings.HasPrefix(strings.ToUpper(params["algorithm"]), digestAlgorithmSHA256) {
+				selected = params
+			}
+		}
+	}
+
+	return selected, selected != nil
+}
+
+// splitChallenges splits a WWW-Authenticate header value carrying several challenges, a new
+// challenge starts with a token that is not followed by '='
+func splitChallenges(v string) []string {
+	var (
+		challenges []string
+		start      int
+	)
+
+	for _, seg := range splitOutsideQuotes(v, ',') {
+		token := strings.TrimSpace(seg.text)
+		if i := strings.IndexAny(token, " ="); i > 0 && token[i] == ' ' && seg.start > start {
+			challenges = append(challenges, v[start:seg.start-1])
+			start = seg.start
+		}
+	}
+
+	return append(challenges, v[start:])
+}
+
+// parseAuthParams parses a comma separated list of auth-params, values may be quoted strings
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+
+	for _, seg := range splitOutsideQuotes(s, ',') {
+		k, v, ok := strings.Cut(strings.TrimSpace(seg.text), "=")
+		if !ok {
+			continue
+		}
+
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+			v = unquote(v[1 : len(v)-1])
+		}
+
+		params[strings.ToLower(strings.TrimSpace(k))] = v
+	}
+
+	return params
+}
+
+type segment struct {
+	text  string
+	start int
+}
+
+func splitOutsideQuotes(s string, sep byte) []segment {
+	var (
+		segments []segment
+		start    int
+		quoted   bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			segments = append(segments, segment{s[start:i], start})
+			start = i + 1
+		}
+	}
+
+	return append(segments, segment{s[start:], start})
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func unquote(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,200 @@
+package client_test
+
+import (
+	"context"
+	"crypto/md5" //nolint: gosec
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+const (
+	testUsername = "Mufasa"
+	testPassword = "Circle of Life"
+	testRealm    = "http-auth@example.org"
+)
+
+type digestServer struct {
+	t         *testing.T
+	algorithm string
+	body      string
+
+	mu         sync.Mutex
+	nonce      int
+	challenges int
+	lastNC     string
+}
+
+func (s *digestServer) currentNonce() string {
+	return fmt.Sprintf("nonce-%d", s.nonce)
+}
+
+func (s *digestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(s.t, err)
+	require.Equal(s.t, s.body, string(body))
+
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Digest ") {
+		s.challenge(w, false)
+		return
+	}
+
+	params := map[string]string{}
+	for _, p := range strings.Split(strings.TrimPrefix(authorization, "Digest "), ", ") {
+		k, v, _ := strings.Cut(p, "=")
+		params[k] = strings.Trim(v, `"`)
+	}
+
+	if params["nonce"] != s.currentNonce() {
+		s.challenge(w, true)
+		return
+	}
+
+	var newHash func() hash.Hash = md5.New
+	if strings.EqualFold(s.algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+	h := func(v string) string {
+		hh := newHash()
+		_, _ = hh.Write([]byte(v))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	ha1 := h(testUsername + ":" + testRealm + ":" + testPassword)
+	ha2 := h(r.Method + ":" + r.URL.RequestURI())
+	expected := h(strings.Join([]string{ha1, params["nonce"], params["nc"], params["cnonce"], "auth", ha2}, ":"))
+
+	// the algorithm is echoed as the server sent it
+	if params["response"] != expected || params["username"] != testUsername || params["opaque"] != "opaque-value" ||
+		params["algorithm"] != s.algorithm {
+		s.challenge(w, false)
+		return
+	}
+
+	require.Greater(s.t, params["nc"], s.lastNC)
+	s.lastNC = params["nc"]
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *digestServer) challenge(w http.ResponseWriter, stale bool) {
+	s.challenges++
+
+	w.Header().Add("WWW-Authenticate", `Basic realm="`+testRealm+`"`)
+	w.Header().Add("WWW-Authenticate", fmt.Sprintf(
+		`Digest realm="%s", qop="auth, auth-int", algorithm=%s, nonce="%s", opaque="opaque-value", stale=%t`,
+		testRealm, s.algorithm, s.currentNonce(), stale))
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func TestBasicAuth(t *testing.T) {
+	t.Run("Credentials are sent with the request", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			require.True(t, ok)
+			require.Equal(t, testUsername, username)
+			require.Equal(t, testPassword, password)
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		defer server.Close()
+
+		testClient := client.New(client.WithBasicAuth(testUsername, testPassword))
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.NotNil(t, resp)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+}
+
+func TestDigestAuth(t *testing.T) {
+	const body = `test body`
+
+	for _, algorithm := range []string{"MD5", "SHA-256", "sha-256"} {
+		algorithm := algorithm
+
+		t.Run(fmt.Sprintf("Answer %s challenge and reuse it for subsequent requests", algorithm), func(t *testing.T) {
+			handler := &digestServer{t: t, algorithm: algorithm, body: body}
+			server := httptest.NewServer(handler)
+
+			defer server.Close()
+
+			testClient := client.New(client.WithDigestAuth(testUsername, testPassword))
+
+			for i := 0; i < 3; i++ {
+				resp, err := testClient.Post(context.Background(), server.URL+"/dir/index.html?q=1", strings.NewReader(body))
+				require.NoError(t, err)
+				require.NotNil(t, resp)
+
+				resp.Body.Close()
+
+				assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+			}
+
+			assert.Equal(t, 1, handler.challenges)
+		})
+	}
+
+	t.Run("Refresh stale nonce", func(t *testing.T) {
+		handler := &digestServer{t: t, algorithm: "MD5", body: body}
+		server := httptest.NewServer(handler)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithDigestAuth(testUsername, testPassword))
+
+		resp, err := testClient.Post(context.Background(), server.URL, strings.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		handler.mu.Lock()
+		handler.nonce++
+		handler.lastNC = ""
+		handler.mu.Unlock()
+
+		resp, err = testClient.Post(context.Background(), server.URL, strings.NewReader(body))
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, 2, handler.challenges)
+	})
+
+	t.Run("Return the 401 response if credentials are wrong", func(t *testing.T) {
+		handler := &digestServer{t: t, algorithm: "MD5", body: ""}
+		server := httptest.NewServer(handler)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithDigestAuth(testUsername, "wrong password"))
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, 2, handler.challenges)
+	})
+}
@@ -0,0 +1,213 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// errBodyNotReplayable is returned when a request body streamed once is needed by another attempt
+var errBodyNotReplayable = errors.New("request body is too large to be replayed")
+
+// requestBody provides the request body of each attempt
+type requestBody interface {
+	// next returns the body to send with the next attempt, closing it doesn't close the requestBody
+	next() (io.ReadCloser, error)
+	// replayable tells whether next can be called more than once
+	replayable() bool
+	// Close releases the resources held by the requestBody
+	Close() error
+}
+
+// getBodyBody replays a body through the GetBody function of its request, which returns a new copy of
+// the body on each call
+type getBodyBody struct {
+	body    io.ReadCloser
+	getBody func() (io.ReadCloser, error)
+}
+
+func (b *getBodyBody) next() (io.ReadCloser, error) {
+	return b.getBody()
+}
+
+func (b *getBodyBody) replayable() bool {
+	return true
+}
+
+func (b *getBodyBody) Close() error {
+	return b.body.Close()
+}
+
+// seekableBody replays a seekable body by seeking back to its start before each attempt
+type seekableBody struct {
+	body io.ReadSeekCloser
+}
+
+func (b *seekableBody) next() (io.ReadCloser, error) {
+	if _, err := b.body.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// wrap the body in io.NopCloser to prevent the body from being closed
+	return io.NopCloser(b.body), nil
+}
+
+func (b *seekableBody) replayable() bool {
+	return true
+}
+
+func (b *seekableBody) Close() error {
+	return b.body.Close()
+}
+
+// oneShotBody streams a body once, the request cannot be retried
+type oneShotBody struct {
+	body io.ReadCloser
+	used bool
+}
+
+func (b *oneShotBody) next() (io.ReadCloser, error) {
+	if b.used {
+		return nil, errBodyNotReplayable
+	}
+	b.used = true
+
+	return io.NopCloser(b.body), nil
+}
+
+func (b *oneShotBody) replayable() bool {
+	return false
+}
+
+func (b *oneShotBody) Close() error {
+	return b.body.Close()
+}
+
+// spooledFile is a temporary file removed once closed
+type spooledFile struct {
+	*os.File
+}
+
+func (f *spooledFile) Close() error {
+	err := f.File.Close()
+	if rmErr := os.Remove(f.Name()); err == nil {
+		err = rmErr
+	}
+
+	return err
+}
+
+// newRequestBody prepares the body of req to be sent by several attempts. Bodies are replayed through
+// req.GetBody when set, seekable bodies are used as is, other bodies are buffered by bufferBody.
+func newRequestBody(req *http.Request, memoryLimit, maxReplayableSize int64) (requestBody, error) {
+	if req.GetBody != nil {
+		return &getBodyBody{body: req.Body, getBody: req.GetBody}, nil
+	}
+
+	if rsc, ok := req.Body.(io.ReadSeekCloser); ok {
+		return &seekableBody{rsc}, nil
+	}
+
+	if maxReplayableSize > 0 && req.ContentLength > maxReplayableSize {
+		return &oneShotBody{body: req.Body}, nil
+	}
+
+	return bufferBody(req.Body, memoryLimit, maxReplayableSize)
+}
+
+// bufferBody buffers body to replay it: in memory up to memoryLimit bytes, in a temporary file beyond.
+// Bodies larger than maxReplayableSize are streamed once. A limit of zero means no limit.
+func bufferBody(body io.ReadCloser, memoryLimit, maxReplayableSize int64) (requestBody, error) {
+	if memoryLimit <= 0 {
+		limited := io.Reader(body)
+		if maxReplayableSize > 0 {
+			limited = io.LimitReader(body, maxReplayableSize+1)
+		}
+
+		bodyBytes, err := io.ReadAll(limited)
+		if err != nil {
+			_ = body.Close()
+			return nil, err
+		}
+
+		if maxReplayableSize > 0 && int64(len(bodyBytes)) > maxReplayableSize {
+			return &oneShotBody{body: &multiReadCloser{
+				Reader:  io.MultiReader(bytes.NewReader(bodyBytes), body),
+				closers: []io.Closer{body},
+			}}, nil
+		}
+
+		_ = body.Close()
+
+		return &seekableBody{NewBytesSeekReader(bodyBytes)}, nil
+	}
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(body, memoryLimit+1)); err != nil {
+		_ = body.Close()
+		return nil, err
+	}
+
+	if int64(buf.Len()) <= memoryLimit {
+		_ = body.Close()
+		return &seekableBody{NewBytesSeekReader(buf.Bytes())}, nil
+	}
+
+	return spool(io.MultiReader(&buf, body), body, maxReplayableSize)
+}
+
+// spool copies the body to a temporary file, if the body exceeds maxReplayableSize the spooled part
+// and the rest of the body are streamed once
+func spool(body io.Reader, closer io.Closer, maxReplayableSize int64) (requestBody, error) {
+	f, err := os.CreateTemp("", "http-client-body-*")
+	if err != nil {
+		_ = closer.Close()
+		return nil, errors.Wrap(err, "error creating spool file")
+	}
+	spooled := &spooledFile{f}
+
+	limited := body
+	if maxReplayableSize > 0 {
+		limited = io.LimitReader(body, maxReplayableSize+1)
+	}
+
+	n, err := io.Copy(spooled, limited)
+	if err == nil {
+		_, err = spooled.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = spooled.Close()
+		_ = closer.Close()
+		return nil, err
+	}
+
+	if maxReplayableSize > 0 && n > maxReplayableSize {
+		return &oneShotBody{body: &multiReadCloser{
+			Reader:  io.MultiReader(spooled, body),
+			closers: []io.Closer{spooled, closer},
+		}}, nil
+	}
+
+	_ = closer.Close()
+
+	return &seekableBody{spooled}, nil
+}
+
+type multiReadCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiReadCloser) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if cErr := c.Close(); err == nil {
+			err = cErr
+		}
+	}
+
+	return err
+}
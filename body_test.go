@@ -0,0 +1,191 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// newFlakyServer starts a server dropping the connection of the first failures requests, it checks
+// that every request carries body
+func newFlakyServer(t *testing.T, body string, failures int32) (*httptest.Server, *atomic.Int32) {
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, body, string(reqBody))
+
+		if attempts.Add(1) <= failures {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	return server, &attempts
+}
+
+func TestRequestBodySpooling(t *testing.T) {
+	body := strings.Repeat("0123456789", 1000)
+
+	t.Run("Spool large bodies to a temporary file for retries", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+
+		server, attempts := newFlakyServer(t, body, 1)
+
+		defer server.Close()
+
+		testClient := client.New(
+			client.WithRetryPolicy(time.Second, 3),
+			client.WithRequestBodySpooling(1024, 0),
+		)
+
+		// io.MultiReader hides the type of the body, so that the body has to be buffered
+		resp, err := testClient.Post(context.Background(), server.URL, io.MultiReader(strings.NewReader(body)))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(2), attempts.Load())
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Stream bodies larger than the replayable size once", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		t.Setenv("TMPDIR", tmpDir)
+
+		server, attempts := newFlakyServer(t, body, 1)
+
+		defer server.Close()
+
+		testClient := client.New(
+			client.WithRetryPolicy(time.Second, 3),
+			client.WithRequestBodySpooling(1024, 4096),
+		)
+
+		resp, err := testClient.Post(context.Background(), server.URL, io.MultiReader(strings.NewReader(body))) //nolint: bodyclose
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, int32(1), attempts.Load())
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		// the body is streamed entirely when the request succeeds
+		server, attempts = newFlakyServer(t, body, 0)
+
+		defer server.Close()
+
+		resp, err = testClient.Post(context.Background(), server.URL, io.MultiReader(strings.NewReader(body)))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+	t.Run("Stream bodies larger than the replayable size once without memory limit", func(t *testing.T) {
+		server, attempts := newFlakyServer(t, body, 1)
+
+		defer server.Close()
+
+		testClient := client.New(
+			client.WithRetryPolicy(time.Second, 3),
+			client.WithRequestBodySpooling(0, 4096),
+		)
+
+		resp, err := testClient.Post(context.Background(), server.URL, io.MultiReader(strings.NewReader(body))) //nolint: bodyclose
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+		assert.Equal(t, int32(1), attempts.Load())
+
+		// bodies up to the replayable size are buffered in memory and retried
+		server, attempts = newFlakyServer(t, body[:4096], 1)
+
+		defer server.Close()
+
+		resp, err = testClient.Post(context.Background(), server.URL, io.MultiReader(strings.NewReader(body[:4096])))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+}
+
+func TestRequestBodyReplay(t *testing.T) {
+	body := "content"
+
+	t.Run("Replay bodies through GetBody", func(t *testing.T) {
+		server, attempts := newFlakyServer(t, body, 1)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, strings.NewReader(body))
+		require.NoError(t, err)
+
+		var getBodyCalls atomic.Int32
+		getBody := req.GetBody
+		req.GetBody = func() (io.ReadCloser, error) {
+			getBodyCalls.Add(1)
+			return getBody()
+		}
+
+		resp, err := testClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(2), attempts.Load())
+		assert.Equal(t, int32(2), getBodyCalls.Load())
+	})
+
+	t.Run("Follow redirects preserving the body", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/target", http.StatusTemporaryRedirect)
+		})
+		mux.HandleFunc("/target", func(w http.ResponseWriter, r *http.Request) {
+			_, err := io.Copy(w, r.Body)
+			require.NoError(t, err)
+		})
+
+		server := httptest.NewServer(mux)
+
+		defer server.Close()
+
+		testClient := client.New()
+
+		resp, err := testClient.Post(context.Background(), server.URL+"/redirect", io.MultiReader(strings.NewReader(body)))
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, body, string(respBody))
+	})
+}
@@ -0,0 +1,567 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// CacheStatusHeader is set on the responses of a Client configured with a cache to tell how the
+	// response was obtained
+	CacheStatusHeader = "X-Cache"
+
+	// CacheHit means the response was served from the cache without contacting the server
+	CacheHit = "HIT"
+	// CacheMiss means the response was obtained from the server
+	CacheMiss = "MISS"
+	// CacheRevalidated means the cached response was validated by the server with a conditional request
+	CacheRevalidated = "REVALIDATED"
+	// CacheStale means a stale cached response was served, either while it is revalidated in the
+	// background or because the server could not be reached
+	CacheStale = "STALE"
+
+	// heuristic freshness is a fraction of the time since the response was last modified, capped
+	heuristicFreshnessFraction = 10
+	maxHeuristicFreshness      = 24 * time.Hour
+
+	// responses with larger bodies are passed through without being stored
+	maxCacheEntryBytes = 8 << 20
+)
+
+// statuses whose responses are cacheable without explicit freshness, RFC 9110 section 15.1
+var heuristicallyCacheableStatuses = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// Cache stores the serialized responses cached by a Client, implementations must be safe for concurrent
+// use. NewMemoryCache() and NewDiskCache() provide in-memory and on-disk storages.
+//
+// A Client configured with a Cache caches the responses to GET requests following RFC 9111: it honors the
+// Cache-Control (no-store, no-cache, max-age, s-maxage, private, public), Expires and Vary headers, computes
+// heuristic freshness from Last-Modified, and revalidates stale responses with conditional requests built
+// from their ETag and Last-Modified validators. Successful responses to unsafe requests invalidate the
+// cached response of their URL. Stale responses are served under the stale-while-revalidate and
+// stale-if-error directives of RFC 5861, see WithStaleWhileRevalidate() and WithStaleIfError().
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, entry []byte)
+	Delete(key string)
+}
+
+type cacheOptions struct {
+	store  Cache
+	shared bool
+
+	// keys of the entries being revalidated in the background
+	revalidating *sync.Map
+}
+
+func newCacheOptions(store Cache, shared bool) *cacheOptions {
+	if store == nil {
+		return nil
+	}
+
+	return &cacheOptions{
+		store:        store,
+		shared:       shared,
+		revalidating: &sync.Map{},
+	}
+}
+
+func (o *cacheOptions) startRevalidation(key string) bool {
+	_, loaded := o.revalidating.LoadOrStore(key, struct{}{})
+	return !loaded
+}
+
+func (o *cacheOptions) finishRevalidation(key string) {
+	o.revalidating.Delete(key)
+}
+
+// cacheEntry is the serialized form of a cached response
+type cacheEntry struct {
+	RequestTime  time.Time   `json:"request_time"`
+	ResponseTime time.Time   `json:"response_time"`
+	Vary         http.Header `json:"vary,omitempty"`
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+}
+
+func cacheKey(req *http.Request) string {
+	return http.MethodGet + " " + req.URL.String()
+}
+
+func (c *Client) doCached(req *http.Request, opts options) (*http.Response, error) {
+	cOpts := opts.cacheOptions
+	reqCC := parseCacheControl(req.Header)
+
+	if req.Method != http.MethodGet {
+		resp, err := c.do(req, opts)
+		if err == nil && !isSafeMethod(req.Method) && resp.StatusCode < http.StatusBadRequest {
+			cOpts.store.Delete(cacheKey(req))
+		}
+
+		return resp, err
+	}
+
+	// the cache stores whole responses, which partial requests would be served otherwise
+	if _, ok := reqCC["no-store"]; ok || req.Header.Get("Range") != "" {
+		return c.do(req, opts)
+	}
+
+	key := cacheKey(req)
+	entry := loadCacheEntry(cOpts.store, key, req)
+
+	if entry != nil {
+		now := clockOf(opts).Now()
+		if entry.isFresh(now, cOpts.shared, reqCC) {
+			return entry.response(req, CacheHit, now), nil
+		}
+
+		if _, noCache := reqCC["no-cache"]; !noCache &&
+			entry.isServableStale(now, cOpts.shared, "stale-while-revalidate", opts.staleWhileRevalidate) {
+			if cOpts.startRevalidation(key) {
+				go c.revalidate(req.Clone(context.Background()), key, entry, opts)
+			}
+
+			return entry.response(req, CacheStale, now), nil
+		}
+	}
+
+	resp, err := c.fetch(req, key, entry, opts)
+
+	if entry != nil && (err != nil || isServerError(resp.StatusCode)) &&
+		entry.isServableStale(clockOf(opts).Now(), cOpts.shared, "stale-if-error", opts.staleIfError) {
+		if err == nil {
+			drainAndClose(resp)
+		}
+
+		return entry.response(req, CacheStale, clockOf(opts).Now()), nil
+	}
+
+	return resp, err
+}
+
+// fetch sends req to the server, conditionally if a stored entry is being revalidated, and stores the
+// response once its body has been read
+func (c *Client) fetch(req *http.Request, key string, entry *cacheEntry, opts options) (*http.Response, error) {
+	cOpts := opts.cacheOptions
+	clock := clockOf(opts)
+
+	if entry != nil {
+		// the request is cloned to keep the caller's headers untouched
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	requestTime := clock.Now()
+
+	resp, err := c.do(req, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil && resp.StatusCode == http.StatusNotModified {
+		drainAndClose(resp)
+
+		now := clock.Now()
+		entry.update(resp, requestTime, now)
+		storeCacheEntry(cOpts.store, key, entry)
+
+		return entry.response(req, CacheRevalidated, now), nil
+	}
+
+	resp.Header.Set(CacheStatusHeader, CacheMiss)
+
+	if !isStorable(req, resp, cOpts.shared) {
+		return resp, nil
+	}
+
+	resp.Body = &cachingReadCloser{
+		// the bodies of the responses of do are responseBodyReadClosers
+		readCloser: resp.Body.(*responseBodyReadCloser),
+		onEOF: func(body []byte) {
+			entry := newCacheEntry(req, resp, requestTime, clock.Now())
+			entry.Body = body
+			storeCacheEntry(cOpts.store, key, entry)
+		},
+	}
+
+	return resp, nil
+}
+
+// revalidate refreshes a stored entry in the background after a stale response was served
+func (c *Client) revalidate(req *http.Request, key string, entry *cacheEntry, opts options) {
+	defer opts.cacheOptions.finishRevalidation(key)
+
+	resp, err := c.fetch(req, key, entry, opts)
+	if err != nil {
+		return
+	}
+
+	// the response is stored once its body has been read entirely
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+func loadCacheEntry(store Cache, key string, req *http.Request) *cacheEntry {
+	b, ok := store.Get(key)
+	if !ok {
+		return nil
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		store.Delete(key)
+		return nil
+	}
+
+	// a stored response is only used for requests carrying the same values of the headers it varies on
+	for name := range entry.Vary {
+		if strings.Join(req.Header.Values(name), ", ") != entry.Vary.Get(name) {
+			return nil
+		}
+	}
+
+	return entry
+}
+
+func storeCacheEntry(store Cache, key string, entry *cacheEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	store.Set(key, b)
+}
+
+func newCacheEntry(req *http.Request, resp *http.Response, requestTime, responseTime time.Time) *cacheEntry {
+	entry := &cacheEntry{
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+	}
+	entry.Header.Del(CacheStatusHeader)
+
+	for _, v := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			if entry.Vary == nil {
+				entry.Vary = make(http.Header)
+			}
+			entry.Vary.Set(name, strings.Join(req.Header.Values(name), ", "))
+		}
+	}
+
+	return entry
+}
+
+// update refreshes the entry with the headers of a 304 response, RFC 9111 section 4.3.4
+func (e *cacheEntry) update(resp *http.Response, requestTime, responseTime time.Time) {
+	for name, values := range resp.Header {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", CacheStatusHeader:
+			continue
+		}
+
+		e.Header[name] = values
+	}
+
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+func (e *cacheEntry) response(req *http.Request, status string, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(e.currentAge(now)/time.Second), 10))
+	header.Set(CacheStatusHeader, status)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// currentAge implements RFC 9111 section 4.2.3
+func (e *cacheEntry) currentAge(now time.Time) time.Duration {
+	var apparentAge time.Duration
+	if date, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		apparentAge = maxDuration(0, e.ResponseTime.Sub(date))
+	}
+
+	var ageValue time.Duration
+	if age, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && age > 0 {
+		ageValue = time.Duration(age) * time.Second
+	}
+
+	correctedAgeValue := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	correctedInitialAge := maxDuration(apparentAge, correctedAgeValue)
+	residentTime := now.Sub(e.ResponseTime)
+
+	return correctedInitialAge + residentTime
+}
+
+// freshnessLifetime implements RFC 9111 section 4.2.1
+func (e *cacheEntry) freshnessLifetime(shared bool) time.Duration {
+	cc := parseCacheControl(e.Header)
+
+	if shared {
+		if d, ok := cc.seconds("s-maxage"); ok {
+			return d
+		}
+	}
+
+	if d, ok := cc.seconds("max-age"); ok {
+		return d
+	}
+
+	date, dateErr := http.ParseTime(e.Header.Get("Date"))
+	if dateErr != nil {
+		date = e.ResponseTime
+	}
+
+	if expires := e.Header.Get("Expires"); expires != "" {
+		// an invalid Expires, e.g. "0", represents a time in the past
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+
+		return t.Sub(date)
+	}
+
+	if lastModified, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && heuristicallyCacheableStatuses[e.StatusCode] {
+		return minDuration(date.Sub(lastModified)/heuristicFreshnessFraction, maxHeuristicFreshness)
+	}
+
+	return 0
+}
+
+func (e *cacheEntry) isFresh(now time.Time, shared bool, reqCC cacheControl) bool {
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+
+	if _, ok := parseCacheControl(e.Header)["no-cache"]; ok {
+		return false
+	}
+
+	age := e.currentAge(now)
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+
+	return e.freshnessLifetime(shared) > age
+}
+
+// isServableStale tells whether the stale entry may be served under the stale-while-revalidate or
+// stale-if-error directive of RFC 5861, the window configured on the client applies when the response
+// carries no such directive
+func (e *cacheEntry) isServableStale(now time.Time, shared bool, directive string, defaultWindow time.Duration) bool {
+	cc := parseCacheControl(e.Header)
+
+	for _, d := range []string{"no-cache", "must-revalidate"} {
+		if _, ok := cc[d]; ok {
+			return false
+		}
+	}
+
+	if _, ok := cc["proxy-revalidate"]; ok && shared {
+		return false
+	}
+
+	window, ok := cc.seconds(directive)
+	if !ok {
+		window = defaultWindow
+	}
+
+	return window > 0 && e.currentAge(now)-e.freshnessLifetime(shared) <= window
+}
+
+func isServerError(statusCode int) bool {
+	switch statusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isStorable implements RFC 9111 section 3
+func isStorable(req *http.Request, resp *http.Response, shared bool) bool {
+	switch {
+	case req.Method != http.MethodGet, resp.StatusCode < http.StatusOK:
+		return false
+	case resp.StatusCode == http.StatusPartialContent, resp.StatusCode == http.StatusNotModified:
+		// partial and not modified responses are not complete representations
+		return false
+	case isServerError(resp.StatusCode):
+		// transient server errors must not replace the stored response served under stale-if-error
+		return false
+	}
+
+	cc := parseCacheControl(resp.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+
+	for _, v := range resp.Header.Values("Vary") {
+		if strings.TrimSpace(v) == "*" {
+			return false
+		}
+	}
+
+	_, public := cc["public"]
+	_, sMaxAge := cc["s-maxage"]
+	if shared {
+		if _, ok := cc["private"]; ok {
+			return false
+		}
+
+		_, mustRevalidate := cc["must-revalidate"]
+		if req.Header.Get("Authorization") != "" && !public && !sMaxAge && !mustRevalidate {
+			return false
+		}
+	}
+
+	_, maxAge := cc["max-age"]
+
+	return public || maxAge || (shared && sMaxAge) || resp.Header.Get("Expires") != "" ||
+		heuristicallyCacheableStatuses[resp.StatusCode]
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+// cacheControl holds the Cache-Control directives of a message, directive names are lower-cased
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+
+	for _, v := range h.Values("Cache-Control") {
+		for _, seg := range splitOutsideQuotes(v, ',') {
+			name, value, _ := strings.Cut(strings.TrimSpace(seg.text), "=")
+			if name == "" {
+				continue
+			}
+
+			cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+
+	return cc
+}
+
+func (cc cacheControl) seconds(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+
+	s, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || s < 0 {
+		// an invalid delta-seconds value is treated as stale
+		return 0, true
+	}
+
+	return time.Duration(s) * time.Second, true
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// cachingReadCloser keeps a copy of the body read by the caller, the copy is passed to onEOF once the
+// body has been read entirely
+type cachingReadCloser struct {
+	readCloser *responseBodyReadCloser
+	buf        bytes.Buffer
+	onEOF      func([]byte)
+	done       bool
+}
+
+func (rc *cachingReadCloser) Read(p []byte) (int, error) {
+	n, err := rc.readCloser.Read(p)
+
+	if !rc.done {
+		if rc.buf.Len()+n > maxCacheEntryBytes {
+			rc.done = true
+			rc.buf = bytes.Buffer{}
+		} else {
+			rc.buf.Write(p[:n])
+		}
+	}
+
+	if errors.Is(err, io.EOF) && !rc.done {
+		rc.done = true
+		rc.onEOF(rc.buf.Bytes())
+	}
+
+	return n, err
+}
+
+// Close drains the remaining body so that responses whose bodies are not read, e.g. empty bodies, are
+// still stored
+func (rc *cachingReadCloser) Close() error {
+	if !rc.done && rc.readCloser.drainable() {
+		drain(rc, rc.readCloser.cancelFunc)
+	}
+
+	return rc.readCloser.Close()
+}
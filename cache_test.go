@@ -0,0 +1,374 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/clienttest"
+)
+
+// newCachedServer starts a server responding with the body "content" and the headers set by header,
+// it counts the requests it receives
+func newCachedServer(t *testing.T, header func(w http.ResponseWriter, r *http.Request) bool) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if notModified := header(w, r); notModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		_, err := w.Write([]byte("content"))
+		require.NoError(t, err)
+	}))
+
+	return server, &requests
+}
+
+func getCached(t *testing.T, testClient *client.Client, url string, header http.Header) (*http.Response, string) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	require.NoError(t, err)
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := testClient.Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, string(body)
+}
+
+func TestCache(t *testing.T) {
+	t.Run("Serve fresh responses from the cache", func(t *testing.T) {
+		server, requests := newCachedServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("Cache-Control", "max-age=60")
+			return false
+		})
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		resp, body := getCached(t, testClient, server.URL, nil)
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, "content", body)
+
+		resp, body = getCached(t, testClient, server.URL, nil)
+		assert.Equal(t, client.CacheHit, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, "content", body)
+		assert.NotEmpty(t, resp.Header.Get("Age"))
+		assert.Equal(t, int32(1), requests.Load())
+
+		// the request can demand a revalidation
+		resp, _ = getCached(t, testClient, server.URL, http.Header{"Cache-Control": {"no-cache"}})
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("Do not store no-store responses", func(t *testing.T) {
+		server, requests := newCachedServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("Cache-Control", "max-age=60, no-store")
+			return false
+		})
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		getCached(t, testClient, server.URL, nil)
+		resp, _ := getCached(t, testClient, server.URL, nil)
+
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("Revalidate stale responses with their validators", func(t *testing.T) {
+		lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+		server, requests := newCachedServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", lastModified)
+
+			return r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == lastModified
+		})
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		getCached(t, testClient, server.URL, nil)
+		resp, body := getCached(t, testClient, server.URL, nil)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, client.CacheRevalidated, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, "content", body)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("Compute heuristic freshness and honor Expires", func(t *testing.T) {
+		server, requests := newCachedServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			if r.URL.Path == "/expired" {
+				w.Header().Set("Expires", "0")
+			}
+			w.Header().Set("Last-Modified", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+			return false
+		})
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		getCached(t, testClient, server.URL+"/heuristic", nil)
+		resp, _ := getCached(t, testClient, server.URL+"/heuristic", nil)
+		assert.Equal(t, client.CacheHit, resp.Header.Get(client.CacheStatusHeader))
+
+		getCached(t, testClient, server.URL+"/expired", nil)
+		resp, _ = getCached(t, testClient, server.URL+"/expired", nil)
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("Select stored responses with the headers they vary on", func(t *testing.T) {
+		server, requests := newCachedServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			return false
+		})
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		getCached(t, testClient, server.URL, http.Header{"Accept-Language": {"en"}})
+		resp, _ := getCached(t, testClient, server.URL, http.Header{"Accept-Language": {"en"}})
+		assert.Equal(t, client.CacheHit, resp.Header.Get(client.CacheStatusHeader))
+
+		resp, _ = getCached(t, testClient, server.URL, http.Header{"Accept-Language": {"fr"}})
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("Invalidate stored responses after unsafe requests", func(t *testing.T) {
+		server, requests := newCachedServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("Cache-Control", "max-age=60")
+			return false
+		})
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		getCached(t, testClient, server.URL, nil)
+
+		resp, err := testClient.Post(context.Background(), server.URL, strings.NewReader("update"))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		resp, _ = getCached(t, testClient, server.URL, nil)
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("Send range requests to the server", func(t *testing.T) {
+		server, requests := newCachedServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("Cache-Control", "max-age=60")
+			return false
+		})
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		getCached(t, testClient, server.URL, nil)
+
+		resp, _ := getCached(t, testClient, server.URL, http.Header{"Range": {"bytes=2-"}})
+		assert.Empty(t, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, int32(2), requests.Load())
+
+		resp, _ = getCached(t, testClient, server.URL, nil)
+		assert.Equal(t, client.CacheHit, resp.Header.Get(client.CacheStatusHeader))
+	})
+
+	t.Run("Shared caches honor s-maxage and private", func(t *testing.T) {
+		server, requests := newCachedServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			if r.URL.Path == "/private" {
+				w.Header().Set("Cache-Control", "private, max-age=60")
+			} else {
+				w.Header().Set("Cache-Control", "max-age=0, s-maxage=60")
+			}
+			return false
+		})
+
+		defer server.Close()
+
+		dir := t.TempDir()
+		cache, err := client.NewDiskCache(dir)
+		require.NoError(t, err)
+
+		testClient := client.New(client.WithSharedCache(cache))
+
+		getCached(t, testClient, server.URL+"/shared", nil)
+		resp, _ := getCached(t, testClient, server.URL+"/shared", nil)
+		assert.Equal(t, client.CacheHit, resp.Header.Get(client.CacheStatusHeader))
+
+		getCached(t, testClient, server.URL+"/private", nil)
+		resp, _ = getCached(t, testClient, server.URL+"/private", nil)
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+
+		assert.Equal(t, int32(3), requests.Load())
+
+		// the cache can be bypassed per request
+		resp, err = testClient.Get(context.Background(), server.URL+"/shared", client.WithCache(nil))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Empty(t, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, int32(4), requests.Load())
+	})
+}
+
+func TestMemoryCache(t *testing.T) {
+	t.Run("Evict the least recently used entries", func(t *testing.T) {
+		cache := client.NewMemoryCache(10)
+
+		cache.Set("a", []byte("aaaa"))
+		cache.Set("b", []byte("bbbb"))
+
+		_, ok := cache.Get("a")
+		require.True(t, ok)
+
+		cache.Set("c", []byte("cccc"))
+
+		_, ok = cache.Get("b")
+		assert.False(t, ok)
+
+		v, ok := cache.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, "aaaa", string(v))
+
+		cache.Delete("a")
+		_, ok = cache.Get("a")
+		assert.False(t, ok)
+	})
+}
+
+func TestStaleCache(t *testing.T) {
+	t.Run("Serve stale responses while revalidating in the background", func(t *testing.T) {
+		var version atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60")
+			_, err := w.Write([]byte(fmt.Sprintf("v%d", version.Add(1))))
+			require.NoError(t, err)
+		}))
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		_, body := getCached(t, testClient, server.URL, nil)
+		assert.Equal(t, "v1", body)
+
+		resp, body := getCached(t, testClient, server.URL, nil)
+		assert.Equal(t, client.CacheStale, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, "v1", body)
+
+		require.Eventually(t, func() bool {
+			_, body := getCached(t, testClient, server.URL, nil)
+			return body == "v2"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Serve stale responses when the server fails", func(t *testing.T) {
+		var failing atomic.Bool
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/directive" {
+				w.Header().Set("Cache-Control", "max-age=0, stale-if-error=60")
+			} else {
+				w.Header().Set("Cache-Control", "max-age=0")
+			}
+
+			if failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			_, err := w.Write([]byte("content"))
+			require.NoError(t, err)
+		}))
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		getCached(t, testClient, server.URL+"/directive", nil)
+		getCached(t, testClient, server.URL+"/configured", nil)
+
+		failing.Store(true)
+
+		resp, body := getCached(t, testClient, server.URL+"/directive", nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, client.CacheStale, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, "content", body)
+
+		resp, _ = getCached(t, testClient, server.URL+"/configured", nil)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/configured", nil)
+		require.NoError(t, err)
+
+		resp, err = testClient.Do(req, client.WithStaleIfError(time.Minute))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, client.CacheStale, resp.Header.Get(client.CacheStatusHeader))
+
+		// unreachable servers are handled alike once all retries failed
+		server.Close()
+
+		resp, err = testClient.Do(req, client.WithStaleIfError(time.Minute), client.WithRetryPolicy(time.Second, 2))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, client.CacheStale, resp.Header.Get(client.CacheStatusHeader))
+	})
+
+	t.Run("Don't serve stale responses without a window", func(t *testing.T) {
+		clock := clienttest.NewFakeClock(time.Now())
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodGet, "/").Respond(http.StatusOK, "content").WithHeader("Cache-Control", "max-age=60")
+
+		testClient := mock.Client(client.WithClock(clock), client.WithCache(client.NewMemoryCache(1<<20)))
+
+		getCached(t, testClient, "http://api/", nil)
+
+		// the response expires exactly now
+		clock.Advance(60 * time.Second)
+		resp, _ := getCached(t, testClient, "http://api/", nil)
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+
+		assert.Len(t, mock.Calls(), 2)
+	})
+}
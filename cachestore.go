@@ -0,0 +1,141 @@
+package client
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MemoryCache is an in-memory Cache evicting the least recently used entries once the total size of
+// the entries exceeds its capacity
+type MemoryCache struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryCache creates a MemoryCache holding up to maxBytes bytes of entries
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	m.order.MoveToFront(e)
+
+	return e.Value.(*memoryCacheEntry).value, true
+}
+
+func (m *MemoryCache) Set(key string, entry []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+
+	if int64(len(entry)) > m.maxBytes {
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryCacheEntry{key: key, value: entry})
+	m.size += int64(len(entry))
+
+	for m.size > m.maxBytes {
+		m.remove(m.order.Back().Value.(*memoryCacheEntry).key)
+	}
+}
+
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+}
+
+func (m *MemoryCache) remove(key string) {
+	e, ok := m.entries[key]
+	if !ok {
+		return
+	}
+
+	m.order.Remove(e)
+	delete(m.entries, key)
+	m.size -= int64(len(e.Value.(*memoryCacheEntry).value))
+}
+
+// DiskCache is a Cache storing each entry in a file of a directory, entries are not evicted
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache creates a DiskCache storing its entries in dir, dir is created if it doesn't exist
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.Wrap(err, "error creating cache directory")
+	}
+
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	b, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return b, true
+}
+
+// Set writes the entry to a temporary file renamed over the entry file, so that concurrent readers
+// never observe partially written entries
+func (d *DiskCache) Set(key string, entry []byte) {
+	f, err := os.CreateTemp(d.dir, "tmp-*")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(entry)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), d.path(key))
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+func (d *DiskCache) Delete(key string) {
+	_ = os.Remove(d.path(key))
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
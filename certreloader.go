@@ -0,0 +1,138 @@
+package client
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CertReloader keeps a client certificate in sync with its PEM encoded certificate and key files.
+// The files are polled every interval, whenever either of them changes the key pair is loaded again
+// and swapped atomically, if loading fails the previous certificate stays in use and onError is called.
+//
+// Pass a CertReloader to WithCertReloader() so that every new TLS connection of the Client presents
+// the latest certificate.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	onError  func(error)
+
+	cert     atomic.Pointer[tls.Certificate]
+	mu       sync.Mutex
+	modTimes [2]time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCertReloader loads the key pair from certFile and keyFile and starts watching them, if interval is
+// zero the files are not watched and the certificate is only reloaded by calling Reload(). onError may
+// be nil.
+func NewCertReloader(certFile, keyFile string, interval time.Duration, onError func(error)) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		onError:  onError,
+		stop:     make(chan struct{}),
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	if interval > 0 {
+		go r.watch(interval)
+	}
+
+	return r, nil
+}
+
+// Reload loads the key pair from the certificate and key files unconditionally
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+
+	return r.load(modTimes)
+}
+
+// GetClientCertificate returns the current certificate, it satisfies tls.Config.GetClientCertificate
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.cert.Load(), nil
+}
+
+// Close stops watching the certificate files
+func (r *CertReloader) Close() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+
+	return nil
+}
+
+func (r *CertReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if err := r.reloadIfModified(); err != nil && r.onError != nil {
+				r.onError(err)
+			}
+		}
+	}
+}
+
+func (r *CertReloader) reloadIfModified() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+
+	if modTimes == r.modTimes {
+		return nil
+	}
+
+	return r.load(modTimes)
+}
+
+func (r *CertReloader) statFiles() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+
+	for i, f := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(f)
+		if err != nil {
+			return modTimes, errors.Wrap(err, "error checking certificate file")
+		}
+
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func (r *CertReloader) load(modTimes [2]time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "error loading client certificate")
+	}
+
+	r.cert.Store(&cert)
+	r.modTimes = modTimes
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/kamilsk/retry/v5"
+	"github.com/kamilsk/retry/v5/strategy"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	tracinglog "github.com/opentracing/opentracing-go/log"
@@ -21,8 +22,31 @@ const (
 
 	stdBackOffExponentialFactor = 1 * time.Millisecond
 	stdBackOffJitterDeviation   = 0.25
+
+	// a challenge is answered at most twice per attempt: the initial challenge and a stale nonce
+	maxAuthRounds = 2
+	maxDrainBytes = 64 << 10
+	// the rest of a body is drained on Close only if it arrives within maxDrainDuration
+	maxDrainDuration = 50 * time.Millisecond
 )
 
+// ErrResponseTooLarge is returned when reading a response body beyond the size limit given to
+// WithMaxResponseBytes
+var ErrResponseTooLarge = errors.New("response body exceeds the size limit")
+
+// AttemptInfo describes an attempt of a request to the observer given to WithAttemptObserver
+type AttemptInfo struct {
+	Request *http.Request
+	// Attempt is the number of the attempt, starting from 1
+	Attempt int
+	Start   time.Time
+	// Duration is the time the attempt took to get the response headers, or to fail
+	Duration time.Duration
+	// Response is the response of the attempt, nil if the attempt failed, its body must not be read
+	Response *http.Response
+	Err      error
+}
+
 type Client struct {
 	options   options
 	generator *rand.Rand
@@ -34,6 +58,9 @@ type Client struct {
 // can pass options to each request to override the client options.
 //
 // If user doesn't specify retry policy, a standard retry policy will be added by default
+//
+// Transport options (TLS, certificates and the like) only take effect when passed to New(), if none
+// is specified the Client sends requests through http.DefaultClient
 
 func New(opts ...Option) *Client {
 	// it is fine to use a weak random number generator in this  scenario
@@ -55,7 +82,7 @@ func New(opts ...Option) *Client {
 	return &Client{
 		options:   clientOpts,
 		generator: generator,
-		client:    http.DefaultClient,
+		client:    newHTTPClient(clientOpts.transportOptions),
 	}
 }
 
@@ -99,25 +126,54 @@ func (c *Client) Post(ctx context.Context, url string, body io.Reader, opts ...O
 // to be closed, if returned error is nil, do method returns a non nil *http.Response, like http.Response, it is
 // user's responsibility to close the response body.
 
-func (c *Client) Do(req *http.Request, opts ...Option) (*http.Response, error) { //nolint: gocyclo
+func (c *Client) Do(req *http.Request, opts ...Option) (*http.Response, error) {
 	requestOpts := c.options
 	for _, o := range opts {
 		o.apply(&requestOpts, c.generator)
 	}
 
-	// read request body, keep a local copy for reuse
+	if requestOpts.decompressionOptions != nil {
+		req = prepareDecompression(req)
+	}
+
+	send := func(req *http.Request) (*http.Response, error) {
+		if requestOpts.cacheOptions != nil {
+			return c.doCached(req, requestOpts)
+		}
+
+		return c.do(req, requestOpts)
+	}
+
+	if requestOpts.coalescer != nil && coalescible(req) {
+		return requestOpts.coalescer.do(req, requestOpts.authenticator, send)
+	}
+
+	return send(req)
+}
+
+// do sends req with retries, it implements Do() once the request options are resolved
+func (c *Client) do(req *http.Request, requestOpts options) (*http.Response, error) { //nolint: gocyclo
+	// prepare request body, keep a local copy for reuse
 	var (
-		reqBody io.ReadSeekCloser
+		reqBody requestBody
 		err     error
 	)
-	if req.Body != nil {
-		reqBody, err = getRequestBodyReadSeekCloser(req)
+	if req.Body != nil && req.Body != http.NoBody {
+		reqBody, err = newRequestBody(req, requestOpts.bodyMemoryLimit, requestOpts.maxReplayableBodySize)
 		if err != nil {
 			return nil, errors.Wrap(err, "error preparing request body")
 		}
 
-		// the reqBody will be wrapped in io.NopCloser in each attempt to prevent
-		// the body from being closed, so we need to explicityly close the reqBody
+		if requestOpts.compressionOptions != nil {
+			compressed, err := compressRequestBody(req, reqBody, requestOpts)
+			if err != nil {
+				_ = reqBody.Close()
+				return nil, err
+			}
+			reqBody = compressed
+		}
+
+		// the body of each attempt doesn't close the reqBody, so we need to explicitly close the reqBody
 		defer reqBody.Close()
 	}
 
@@ -142,41 +198,54 @@ func (c *Client) Do(req *http.Request, opts ...Option) (*http.Response, error) {
 			sp.LogFields(tracinglog.Uint32("attempt", attemptCount))
 		}
 
-		if reqBody != nil {
-			_, aErr = reqBody.Seek(0, io.SeekStart)
-			if aErr != nil {
-				return aErr
-			}
-
-			// wrap the reqBody in io.NopCloser to prevent reqBody from being closed
-			req.Body = io.NopCloser(reqBody)
-		}
-
+		// the attempt context is canceled to abort the body of the response, when it is closed unread
 		var cancelFunc context.CancelFunc
 		if requestOpts.retryPolicy.requestTimeout != time.Duration(0) {
-			aCtx, cancelFunc = context.WithTimeout(aCtx, requestOpts.retryPolicy.requestTimeout)
+			aCtx, cancelFunc = withTimeout(aCtx, clockOf(requestOpts), requestOpts.retryPolicy.requestTimeout)
+		} else {
+			aCtx, cancelFunc = context.WithCancel(aCtx)
 		}
 
-		aReq := req.WithContext(aCtx)
-
-		resp, aErr = c.client.Do(aReq) //nolint: bodyclose
+		start := clockOf(requestOpts).Now()
+		resp, aErr = c.attempt(aCtx, req, reqBody, requestOpts) //nolint: bodyclose
 		attemptCount++
+
+		if requestOpts.attemptObserver != nil {
+			requestOpts.attemptObserver(AttemptInfo{
+				Request:  req,
+				Attempt:  int(attemptCount),
+				Start:    start,
+				Duration: clockOf(requestOpts).Now().Sub(start),
+				Response: resp,
+				Err:      aErr,
+			})
+		}
 		if aErr != nil {
-			if cancelFunc != nil {
-				cancelFunc()
+			cancelFunc()
+
+			// a body streamed once cannot be sent by another attempt
+			if reqBody != nil && !reqBody.replayable() {
+				return &permanentError{aErr}
 			}
-			return aErr
+
+			return markPermanent(aErr)
+		}
+
+		if requestOpts.decompressionOptions != nil {
+			decompressResponse(resp, requestOpts.decompressionOptions)
 		}
 
 		resp.Body = &responseBodyReadCloser{
-			readCloser: resp.Body,
-			cancelFunc: cancelFunc,
+			readCloser:    resp.Body,
+			cancelFunc:    cancelFunc,
+			contentLength: resp.ContentLength,
+			maxBytes:      requestOpts.maxResponseBytes,
 		}
 
 		return nil
 	}
 
-	err = retry.Do(ctx, action, requestOpts.retryPolicy.retryStrategies...)
+	err = unwrapPermanent(retry.Do(ctx, action, requestOpts.retryPolicy.strategies(clockOf(requestOpts))...))
 
 	if sp != nil {
 		ext.Uint32TagName("http.attempt_count").Set(sp, attemptCount)
@@ -201,20 +270,122 @@ func (c *Client) Do(req *http.Request, opts ...Option) (*http.Response, error) {
 	return resp, nil
 }
 
-func getRequestBodyReadSeekCloser(req *http.Request) (io.ReadSeekCloser, error) {
-	rsc, ok := req.Body.(io.ReadSeekCloser)
-	if ok {
-		return rsc, nil
+// attempt sends req once, if an authenticator is configured and the server answers with an authentication
+// challenge, the request is sent again with the credentials computed from the challenge. A compressed
+// body refused by the server is sent again uncompressed.
+func (c *Client) attempt(ctx context.Context, req *http.Request, reqBody requestBody, opts options) (*http.Response, error) {
+	encoded, _ := reqBody.(*encodedBody)
+
+	for round := 0; ; {
+		aReq := req.WithContext(ctx)
+		if opts.authenticator != nil || encoded != nil {
+			aReq.Header = req.Header.Clone()
+		}
+
+		if reqBody != nil {
+			body, err := reqBody.next()
+			if err != nil {
+				return nil, err
+			}
+
+			aReq.Body = body
+			// the std client replays the body through GetBody to follow redirects and retry HTTP/2 requests
+			aReq.GetBody = nil
+			if reqBody.replayable() {
+				aReq.GetBody = reqBody.next
+			}
+		}
+
+		if encoded != nil {
+			encoded.prepare(aReq)
+		}
+
+		if opts.authenticator != nil {
+			if err := opts.authenticator.authorize(aReq); err != nil {
+				return nil, errors.Wrap(err, "error authorizing request")
+			}
+		}
+
+		resp, err := c.client.Do(aReq)
+		if err != nil {
+			return nil, err
+		}
+
+		// the server refused the compressed body, send the body uncompressed
+		if encoded != nil && encoded.fallback(resp) {
+			drainAndClose(resp)
+			continue
+		}
+
+		if opts.authenticator == nil || resp.StatusCode != http.StatusUnauthorized || round >= maxAuthRounds {
+			return resp, nil
+		}
+
+		resend, err := opts.authenticator.challenge(aReq, resp)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, errors.Wrap(err, "error answering authentication challenge")
+		}
+		if !resend {
+			return resp, nil
+		}
+
+		drainAndClose(resp)
+		round++
 	}
+}
 
-	defer req.Body.Close()
+// permanentError marks an attempt error that must not be retried, it deliberately doesn't implement
+// Unwrap() so that retry strategies, which are given the innermost error, see the mark
+type permanentError struct {
+	err error
+}
 
-	bodyBytes, err := io.ReadAll(req.Body)
-	if err != nil {
-		return nil, err
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// markPermanent marks the errors that retrying cannot recover from
+func markPermanent(err error) error {
+	var pinErr *PinViolationError
+	if errors.As(err, &pinErr) {
+		return &permanentError{err}
+	}
+
+	return err
+}
+
+func unwrapPermanent(err error) error {
+	if pe, ok := err.(*permanentError); ok {
+		return pe.err
 	}
 
-	return NewBytesSeekReader(bodyBytes), nil
+	return err
+}
+
+// stopOnPermanentError is a retry strategy that stops retrying after a permanent error
+func stopOnPermanentError(_ strategy.Breaker, _ uint, err error) bool {
+	_, ok := err.(*permanentError)
+	return !ok
+}
+
+// drainAndClose reads the body of resp before closing it when its length is known and small, so that the
+// underlying connection can be reused
+func drainAndClose(resp *http.Response) {
+	if resp.ContentLength >= 0 && resp.ContentLength <= maxDrainBytes {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+	}
+
+	_ = resp.Body.Close()
+}
+
+// drain reads a bounded amount of the remaining body r so that its connection can be reused, it gives up
+// once maxDrainDuration elapsed by calling abort, which must make the pending read fail
+func drain(r io.Reader, abort context.CancelFunc) {
+	timer := time.AfterFunc(maxDrainDuration, abort)
+	defer timer.Stop()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(r, maxDrainBytes))
 }
 
 func startAndInjectSpan(req *http.Request, opts options) (opentracing.Span, context.Context, error) {
@@ -251,20 +422,56 @@ func startAndInjectSpan(req *http.Request, opts options) (opentracing.Span, cont
 	return sp, ctx, nil
 }
 
-// responseBodyReadCloser is an internal readcloser that cancel the timeout
+// responseBodyReadCloser is an internal readcloser that cancel the attempt
 // context after the response body is closed to prevent context leakage
 type responseBodyReadCloser struct {
-	readCloser io.ReadCloser
-	cancelFunc context.CancelFunc
+	readCloser    io.ReadCloser
+	cancelFunc    context.CancelFunc
+	contentLength int64 // -1 when unknown
+	maxBytes      int64 // zero means no limit
+	read          int64
+	exceeded      bool
 }
 
 func (rc *responseBodyReadCloser) Read(p []byte) (n int, err error) {
-	return rc.readCloser.Read(p)
+	if rc.maxBytes <= 0 {
+		n, err = rc.readCloser.Read(p)
+		rc.read += int64(n)
+
+		return n, err
+	}
+	if rc.exceeded {
+		return 0, ErrResponseTooLarge
+	}
+
+	if int64(len(p)) > rc.maxBytes-rc.read+1 {
+		// read one byte past the limit to tell a body of exactly maxBytes bytes from a larger one
+		p = p[:rc.maxBytes-rc.read+1]
+	}
+
+	n, err = rc.readCloser.Read(p)
+	rc.read += int64(n)
+	if rc.read > rc.maxBytes {
+		rc.exceeded = true
+		return n - int(rc.read-rc.maxBytes), ErrResponseTooLarge
+	}
+
+	return n, err
 }
 
+// drainable reports whether the rest of the body may be small enough to be drained
+func (rc *responseBodyReadCloser) drainable() bool {
+	return !rc.exceeded && (rc.contentLength < 0 || rc.contentLength-rc.read <= maxDrainBytes)
+}
+
+// Close drains the rest of the body before closing it so that the connection is reused, provided the rest
+// is small and arrives shortly. Bodies exceeding the size limit or whose rest is larger or slower are closed
+// right away and their connection is discarded.
 func (rc *responseBodyReadCloser) Close() error {
-	if rc.cancelFunc != nil {
-		defer rc.cancelFunc()
+	defer rc.cancelFunc()
+
+	if rc.drainable() {
+		drain(rc, rc.cancelFunc)
 	}
 
 	return rc.readCloser.Close()
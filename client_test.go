@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -16,6 +17,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/clienttest"
 )
 
 type testReadSeekCloser struct {
@@ -156,42 +158,49 @@ func TestDo(t *testing.T) {
 		assert.Equal(t, "testValue", responseContent.Value)
 	})
 
-	t.Run("request options overwrite client options, retry the request", func(t *testing.T) {
-		attemptCount := 0
-		totalAttemptCount := 2
-
-		opentracing.SetGlobalTracer(mocktracer.New())
-
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			spanCtx, err := opentracing.GlobalTracer().Extract(opentracing.HTTPHeaders,
-				opentracing.HTTPHeadersCarrier(r.Header))
-
-			require.NoError(t, err)
-			require.NotNil(t, spanCtx)
+	// doRetried sends req through the mock with the options, timing out the first attempts whose responses
+	// are delayed on the clock
+	doRetried := func(t *testing.T, clock *clienttest.FakeClock, testClient *client.Client, req *http.Request, timedOut int,
+		opts ...client.Option) *http.Response {
+		type result struct {
+			resp *http.Response
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			resp, err := testClient.Do(req, opts...)
+			done <- result{resp, err}
+		}()
+
+		for i := 0; i < timedOut; i++ {
+			// the timeout of the attempt and the delay of the response
+			clock.BlockUntil(2)
+			clock.Advance(500 * time.Millisecond)
+		}
 
-			require.Equal(t, http.MethodPost, r.Method)
+		r := <-done
+		require.NoError(t, r.err)
+		require.NotNil(t, r.resp)
 
-			require.NotNil(t, r.Body)
+		return r.resp
+	}
 
-			reqBody, err := io.ReadAll(r.Body)
-			require.NoError(t, err)
-			require.Equal(t, body, string(reqBody))
-
-			attemptCount++
+	t.Run("request options overwrite client options, retry the request", func(t *testing.T) {
+		totalAttemptCount := 2
 
-			if attemptCount < totalAttemptCount {
-				time.Sleep(600 * time.Millisecond)
-			}
+		opentracing.SetGlobalTracer(mocktracer.New())
 
-			w.WriteHeader(http.StatusNoContent)
-		}))
+		clock := clienttest.NewFakeClock(time.Now())
+		mock := clienttest.NewMockTransport().WithClock(clock)
+		mock.On(http.MethodPost, "/").Respond(http.StatusNoContent, "").Delay(600*time.Millisecond).
+			Respond(http.StatusNoContent, "")
 
-		testClient := client.New(client.WithRetryPolicy(time.Second, 1))
+		testClient := mock.Client(client.WithClock(clock), client.WithRetryPolicy(time.Second, 1))
 
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, strings.NewReader(body))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://api/", strings.NewReader(body))
 		require.NoError(t, err)
 
-		resp, err := testClient.Do(req,
+		resp := doRetried(t, clock, testClient, req, totalAttemptCount-1,
 			client.WithTracingOptions(
 				true,
 				"testOp",
@@ -199,85 +208,74 @@ func TestDo(t *testing.T) {
 			client.WithSpanCarrierInjected(),
 			client.WithRetryPolicy(500*time.Millisecond, uint(totalAttemptCount)),
 		)
-		assert.NoError(t, err)
-		assert.NotNil(t, resp)
 
 		defer resp.Body.Close()
 
-		assert.Equal(t, totalAttemptCount, attemptCount)
+		calls := mock.Calls()
+		require.Len(t, calls, totalAttemptCount)
+		for _, call := range calls {
+			spanCtx, err := opentracing.GlobalTracer().Extract(opentracing.HTTPHeaders,
+				opentracing.HTTPHeadersCarrier(call.Header))
+			require.NoError(t, err)
+			require.NotNil(t, spanCtx)
+
+			assert.Equal(t, body, string(call.Body))
+		}
 	})
 
 	t.Run("Successfully retry when request body is BytesReadSeekCloser", func(t *testing.T) {
-		attemptCount := 0
 		totalAttemptCount := 2
 
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			reqBody, err := io.ReadAll(r.Body)
-			require.NoError(t, err)
-			require.Equal(t, body, string(reqBody))
-
-			attemptCount++
+		clock := clienttest.NewFakeClock(time.Now())
+		mock := clienttest.NewMockTransport().WithClock(clock)
+		mock.On(http.MethodPost, "/").Respond(http.StatusNoContent, "").Delay(600*time.Millisecond).
+			Respond(http.StatusNoContent, "")
 
-			if attemptCount < totalAttemptCount {
-				time.Sleep(600 * time.Millisecond)
-			}
+		testClient := mock.Client(client.WithClock(clock), client.WithRetryPolicy(time.Second, 1))
 
-			w.WriteHeader(http.StatusNoContent)
-		}))
-
-		testClient := client.New(client.WithRetryPolicy(time.Second, 1))
-
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, client.NewBytesSeekReader([]byte(body)))
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://api/", client.NewBytesSeekReader([]byte(body)))
 		require.NoError(t, err)
 
-		resp, err := testClient.Do(req,
+		resp := doRetried(t, clock, testClient, req, totalAttemptCount-1,
 			client.WithRetryPolicy(500*time.Millisecond, uint(totalAttemptCount)),
 		)
-		assert.NoError(t, err)
-		assert.NotNil(t, resp)
 
 		defer resp.Body.Close()
 
-		assert.Equal(t, totalAttemptCount, attemptCount)
+		calls := mock.Calls()
+		require.Len(t, calls, totalAttemptCount)
+		for _, call := range calls {
+			assert.Equal(t, body, string(call.Body))
+		}
 	})
 
 	t.Run("Successfully retry if request body is other types of object that implement io.ReadSeekCloser", func(t *testing.T) {
-		attemptCount := 0
 		totalAttemptCount := 3
 
-		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			reqBody, err := io.ReadAll(r.Body)
-			require.NoError(t, err)
-			require.Equal(t, body, string(reqBody))
-
-			attemptCount++
-
-			if attemptCount < totalAttemptCount {
-				time.Sleep(600 * time.Millisecond)
-			}
-
-			w.WriteHeader(http.StatusNoContent)
-		}))
-
-		defer server.Close()
+		clock := clienttest.NewFakeClock(time.Now())
+		mock := clienttest.NewMockTransport().WithClock(clock)
+		mock.On(http.MethodGet, "/").Respond(http.StatusNoContent, "").Delay(600*time.Millisecond).
+			Respond(http.StatusNoContent, "").Delay(600*time.Millisecond).
+			Respond(http.StatusNoContent, "")
 
-		testClient := client.New()
+		testClient := mock.Client(client.WithClock(clock))
 
 		testBody := &testReadSeekCloser{bytes.NewReader([]byte(body)), 0}
 
-		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, testBody)
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "http://api/", testBody)
 		require.NoError(t, err)
 
-		resp, err := testClient.Do(req,
+		resp := doRetried(t, clock, testClient, req, totalAttemptCount-1,
 			client.WithRetryPolicy(500*time.Millisecond, uint(totalAttemptCount)),
 		)
 
-		assert.NoError(t, err)
-		assert.NotNil(t, resp)
-
 		defer resp.Body.Close()
 
-		assert.Equal(t, totalAttemptCount, attemptCount)
+		calls := mock.Calls()
+		require.Len(t, calls, totalAttemptCount)
+		for _, call := range calls {
+			assert.Equal(t, body, string(call.Body))
+		}
 		assert.Equal(t, uint(1), testBody.closeCount)
 	})
 
@@ -376,3 +374,50 @@ func TestPost(t *testing.T) {
 		assert.Equal(t, statusCode, resp.StatusCode)
 	})
 }
+
+func TestAttemptObserver(t *testing.T) {
+	t.Run("Report every attempt of a request", func(t *testing.T) {
+		errReset := errors.New("connection reset")
+
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodGet, "/").Fail(errReset).Respond(http.StatusServiceUnavailable, "").Respond(http.StatusOK, "ok")
+
+		var attempts []client.AttemptInfo
+		testClient := mock.Client(client.WithRetryPolicy(time.Second, 3), client.WithAttemptObserver(func(info client.AttemptInfo) {
+			attempts = append(attempts, info)
+		}))
+
+		resp, err := testClient.Get(context.Background(), "http://api/")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		// only transport errors are retried
+		require.Len(t, attempts, 2)
+		assert.Equal(t, 1, attempts[0].Attempt)
+		assert.ErrorIs(t, attempts[0].Err, errReset)
+		assert.Nil(t, attempts[0].Response)
+		assert.Equal(t, 2, attempts[1].Attempt)
+		assert.NoError(t, attempts[1].Err)
+		assert.Equal(t, http.StatusServiceUnavailable, attempts[1].Response.StatusCode)
+		assert.Equal(t, "http://api/", attempts[1].Request.URL.String())
+		assert.False(t, attempts[1].Start.Before(attempts[0].Start))
+	})
+
+	t.Run("Call the observers of the client and of the request", func(t *testing.T) {
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodGet, "/").Respond(http.StatusOK, "ok")
+
+		var calls []string
+		testClient := mock.Client(client.WithAttemptObserver(func(client.AttemptInfo) {
+			calls = append(calls, "client")
+		}))
+
+		resp, err := testClient.Get(context.Background(), "http://api/", client.WithAttemptObserver(func(client.AttemptInfo) {
+			calls = append(calls, "request")
+		}))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, []string{"client", "request"}, calls)
+	})
+}
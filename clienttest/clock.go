@@ -0,0 +1,168 @@
+package clienttest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	client "github.com/zackwwu/http-client-go"
+)
+
+// FakeClock is a client.Clock whose time only moves when Advance is called, so that retry sequences run
+// instantly and deterministically. Timers fire during Advance, in the order of their time, functions of
+// AfterFunc are called by Advance itself.
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+}
+
+// NewFakeClock returns a clock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.changed = sync.NewCond(&c.mu)
+
+	return c
+}
+
+// Now implements client.Clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTimer implements client.Clock
+func (c *FakeClock) NewTimer(d time.Duration) client.Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+
+	return t
+}
+
+// AfterFunc implements client.Clock
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) client.Timer {
+	t := &fakeTimer{clock: c, f: f}
+	t.Reset(d)
+
+	return t
+}
+
+// Advance moves the time forward by d, firing the timers due meanwhile
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+
+	for len(c.timers) > 0 && !c.timers[0].when.After(end) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.changed.Broadcast()
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+
+		if t.f != nil {
+			// the function may use the clock
+			c.mu.Unlock()
+			t.f()
+			c.mu.Lock()
+			continue
+		}
+
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Timers returns the number of timers waiting to fire
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// BlockUntil waits until n timers are waiting to fire, it lets tests advance the clock once the code under
+// test, running in another goroutine, started waiting
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for len(c.timers) != n {
+		c.changed.Wait()
+	}
+}
+
+// remove removes t from the pending timers, it reports whether t was pending
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.changed.Broadcast()
+
+			return true
+		}
+	}
+
+	return false
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	c     chan time.Time
+	f     func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	active := c.remove(t)
+	t.when = c.now.Add(d)
+
+	// as the timers of the time package, timers set to a duration of zero or less fire immediately
+	if d <= 0 {
+		if t.f != nil {
+			go t.f()
+			return active
+		}
+
+		select {
+		case t.c <- c.now:
+		default:
+		}
+
+		return active
+	}
+
+	// timers due at the same time fire in the order they were set
+	i := sort.Search(len(c.timers), func(i int) bool {
+		return c.timers[i].when.After(t.when)
+	})
+	c.timers = append(c.timers, nil)
+	copy(c.timers[i+1:], c.timers[i:])
+	c.timers[i] = t
+	c.changed.Broadcast()
+
+	return active
+}
@@ -0,0 +1,72 @@
+package clienttest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zackwwu/http-client-go/clienttest"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Fire timers in order as the clock advances", func(t *testing.T) {
+		clock := clienttest.NewFakeClock(start)
+
+		var fired []time.Time
+		clock.AfterFunc(2*time.Second, func() {
+			fired = append(fired, clock.Now())
+		})
+		timer := clock.NewTimer(time.Second)
+
+		clock.Advance(500 * time.Millisecond)
+		assert.Equal(t, 2, clock.Timers())
+		select {
+		case <-timer.C():
+			t.Fatal("timer fired early")
+		default:
+		}
+
+		clock.Advance(5 * time.Second)
+		assert.Equal(t, start.Add(time.Second), <-timer.C())
+		assert.Equal(t, []time.Time{start.Add(2 * time.Second)}, fired)
+		assert.Equal(t, start.Add(5500*time.Millisecond), clock.Now())
+		assert.Zero(t, clock.Timers())
+	})
+
+	t.Run("Stop and reset timers", func(t *testing.T) {
+		clock := clienttest.NewFakeClock(start)
+
+		var calls int
+		timer := clock.AfterFunc(time.Second, func() {
+			calls++
+		})
+
+		assert.True(t, timer.Stop())
+		assert.False(t, timer.Stop())
+		clock.Advance(time.Minute)
+		assert.Zero(t, calls)
+
+		assert.False(t, timer.Reset(time.Second))
+		assert.True(t, timer.Reset(2*time.Second))
+		clock.Advance(time.Second)
+		assert.Zero(t, calls)
+		clock.Advance(time.Second)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Block until timers are set", func(t *testing.T) {
+		clock := clienttest.NewFakeClock(start)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			<-clock.NewTimer(time.Hour).C()
+		}()
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Hour)
+		<-done
+	})
+}
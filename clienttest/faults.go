@@ -0,0 +1,410 @@
+package clienttest
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	client "github.com/zackwwu/http-client-go"
+)
+
+type faultKind int
+
+const (
+	faultLatency faultKind = iota
+	faultConnectionReset
+	faultTruncatedHeaders
+	faultStatus
+	faultPartialBody
+	faultSlowBody
+)
+
+// Fault is a failure injected by a FaultInjector
+type Fault struct {
+	kind     faultKind
+	duration time.Duration
+	status   int
+	size     int64
+}
+
+// Latency delays the request by d, unless the request is canceled first
+func Latency(d time.Duration) Fault {
+	return Fault{kind: faultLatency, duration: d}
+}
+
+// ConnectionReset fails the request as if the server reset the connection, the request isn't sent
+func ConnectionReset() Fault {
+	return Fault{kind: faultConnectionReset}
+}
+
+// TruncatedHeaders fails the request as if the connection closed while the response headers were read, the
+// request is sent
+func TruncatedHeaders() Fault {
+	return Fault{kind: faultTruncatedHeaders}
+}
+
+// ServerError answers the request with status, typically a 5xx, instead of sending it
+func ServerError(status int) Fault {
+	return Fault{kind: faultStatus, status: status}
+}
+
+// PartialBody cuts the response body after n bytes, reading further fails with io.ErrUnexpectedEOF
+func PartialBody(n int64) Fault {
+	return Fault{kind: faultPartialBody, size: n}
+}
+
+// SlowBody drips the response body, chunkSize bytes every interval
+func SlowBody(chunkSize int64, interval time.Duration) Fault {
+	return Fault{kind: faultSlowBody, size: chunkSize, duration: interval}
+}
+
+// FaultInjector is an http.RoundTripper injecting faults in the requests it sends through a real transport,
+// so that retries and cancellations of the client can be exercised locally. Requests are given the faults
+// of the first enabled rule they match whose schedule fires. Rules and the injector can be changed while
+// requests are running.
+type FaultInjector struct {
+	next http.RoundTripper
+
+	mu        sync.Mutex
+	clock     client.Clock
+	generator *rand.Rand
+	rules     []*FaultRule
+	disabled  bool
+}
+
+// NewFaultInjector returns an injector sending requests through next, http.DefaultTransport when nil, seed
+// makes the probabilistic faults reproducible
+func NewFaultInjector(next http.RoundTripper, seed int64) *FaultInjector {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &FaultInjector{
+		next:      next,
+		generator: rand.New(rand.NewSource(seed)), //nolint: gosec
+	}
+}
+
+// Client returns a client sending its requests through the injector
+func (f *FaultInjector) Client(opts ...client.Option) *client.Client {
+	return client.New(append(opts[:len(opts):len(opts)], client.WithTransport(f))...)
+}
+
+// WithClock times the Latency and SlowBody faults with clock, such as a FakeClock also given to the client,
+// rather than with the time package
+func (f *FaultInjector) WithClock(clock client.Clock) *FaultInjector {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.clock = clock
+	return f
+}
+
+// On adds a rule injecting faults in the requests to host, with or without its port, and whose URL path
+// matches the pattern path, as defined by path.Match. An empty host or path matches any. By default the
+// rule injects its faults in every request it matches.
+func (f *FaultInjector) On(host, path string, faults ...Fault) *FaultRule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := &FaultRule{
+		injector:    f,
+		host:        host,
+		path:        path,
+		faults:      faults,
+		probability: 1,
+	}
+	f.rules = append(f.rules, r)
+
+	return r
+}
+
+// Enable resumes injecting faults
+func (f *FaultInjector) Enable() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.disabled = false
+}
+
+// Disable stops injecting faults, requests are sent untouched until Enable is called
+func (f *FaultInjector) Disable() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.disabled = true
+}
+
+// Clear removes all the rules
+func (f *FaultInjector) Clear() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rules = nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (f *FaultInjector) RoundTrip(req *http.Request) (*http.Response, error) {
+	faults, clock := f.faults(req)
+
+	var partial, slow *Fault
+	for i, fault := range faults {
+		switch fault.kind {
+		case faultLatency:
+			if err := sleep(req.Context(), clock, fault.duration); err != nil {
+				closeRequestBody(req)
+				return nil, err
+			}
+		case faultConnectionReset:
+			closeRequestBody(req)
+			return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+		case faultStatus:
+			closeRequestBody(req)
+			return injectedResponse(req, fault.status), nil
+		case faultPartialBody:
+			partial = &faults[i]
+		case faultSlowBody:
+			slow = &faults[i]
+		}
+	}
+
+	resp, err := f.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fault := range faults {
+		if fault.kind == faultTruncatedHeaders {
+			_ = resp.Body.Close()
+			return nil, errors.Wrap(io.ErrUnexpectedEOF, "clienttest: injected truncated response headers")
+		}
+	}
+
+	if partial != nil {
+		resp.Body = &partialBody{ReadCloser: resp.Body, remaining: partial.size}
+	}
+	if slow != nil {
+		resp.Body = &slowBody{
+			ReadCloser: resp.Body,
+			ctx:        req.Context(),
+			clock:      clock,
+			chunkSize:  slow.size,
+			interval:   slow.duration,
+		}
+	}
+
+	return resp, nil
+}
+
+// faults returns the faults to inject in req, and the clock timing them
+func (f *FaultInjector) faults(req *http.Request) ([]Fault, client.Clock) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.disabled {
+		return nil, f.clock
+	}
+
+	for _, r := range f.rules {
+		if r.disabled || !r.matches(req) {
+			continue
+		}
+
+		r.matched++
+		if r.times > 0 && r.injected >= r.times {
+			continue
+		}
+		if r.every > 0 && r.matched%r.every != 0 {
+			continue
+		}
+		if r.probability < 1 && f.generator.Float64() >= r.probability {
+			continue
+		}
+
+		r.injected++
+		return r.faults, f.clock
+	}
+
+	return nil, f.clock
+}
+
+// FaultRule injects faults in the requests it matches
+type FaultRule struct {
+	injector *FaultInjector
+	host     string
+	path     string
+	faults   []Fault
+
+	probability float64
+	every       int
+	times       int
+	disabled    bool
+
+	matched  int
+	injected int
+}
+
+// WithProbability injects the faults of the rule in a matched request with probability p
+func (r *FaultRule) WithProbability(p float64) *FaultRule {
+	r.injector.mu.Lock()
+	defer r.injector.mu.Unlock()
+
+	r.probability = p
+	return r
+}
+
+// Every injects the faults of the rule in every nth request the rule matches, starting with the nth
+func (r *FaultRule) Every(n int) *FaultRule {
+	r.injector.mu.Lock()
+	defer r.injector.mu.Unlock()
+
+	r.every = n
+	return r
+}
+
+// Times stops injecting the faults of the rule once they were injected n times
+func (r *FaultRule) Times(n int) *FaultRule {
+	r.injector.mu.Lock()
+	defer r.injector.mu.Unlock()
+
+	r.times = n
+	return r
+}
+
+// Enable resumes injecting the faults of the rule
+func (r *FaultRule) Enable() {
+	r.injector.mu.Lock()
+	defer r.injector.mu.Unlock()
+
+	r.disabled = false
+}
+
+// Disable stops injecting the faults of the rule, the requests it matches are matched by the rules that
+// follow it
+func (r *FaultRule) Disable() {
+	r.injector.mu.Lock()
+	defer r.injector.mu.Unlock()
+
+	r.disabled = true
+}
+
+// Injected returns the number of requests the faults of the rule were injected in
+func (r *FaultRule) Injected() int {
+	r.injector.mu.Lock()
+	defer r.injector.mu.Unlock()
+
+	return r.injected
+}
+
+func (r *FaultRule) matches(req *http.Request) bool {
+	if r.host != "" && r.host != req.URL.Host && r.host != req.URL.Hostname() {
+		return false
+	}
+	if r.path == "" {
+		return true
+	}
+
+	ok, _ := path.Match(r.path, req.URL.Path)
+	return ok
+}
+
+// partialBody fails reading past its remaining bytes
+type partialBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *partialBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+
+	return n, err
+}
+
+// slowBody reads chunkSize bytes every interval, until its context is canceled
+type slowBody struct {
+	io.ReadCloser
+	ctx       context.Context
+	clock     client.Clock
+	chunkSize int64
+	interval  time.Duration
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if err := sleep(b.ctx, b.clock, b.interval); err != nil {
+		return 0, err
+	}
+	if b.chunkSize > 0 && int64(len(p)) > b.chunkSize {
+		p = p[:b.chunkSize]
+	}
+
+	return b.ReadCloser.Read(p)
+}
+
+func injectedResponse(req *http.Request, status int) *http.Response {
+	body := http.StatusText(status)
+
+	return &http.Response{
+		Status:     strconv.Itoa(status) + " " + body,
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Length": {strconv.Itoa(len(body))},
+			"Content-Type":   {"text/plain; charset=utf-8"},
+		},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// sleep waits for d on clock, or on the time package when clock is nil, unless ctx is done first
+func sleep(ctx context.Context, clock client.Clock, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	var fired <-chan time.Time
+	if clock != nil {
+		timer := clock.NewTimer(d)
+		defer timer.Stop()
+		fired = timer.C()
+	} else {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		fired = timer.C
+	}
+
+	select {
+	case <-fired:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeRequestBody closes the body of a request that isn't sent, as round trippers must
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
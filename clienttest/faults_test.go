@@ -0,0 +1,223 @@
+package clienttest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/clienttest"
+)
+
+func TestFaultInjector(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer server.Close()
+
+	reset := func() {
+		atomic.StoreInt32(&requests, 0)
+	}
+
+	t.Run("Retry requests failed by connection resets", func(t *testing.T) {
+		defer reset()
+
+		injector := clienttest.NewFaultInjector(nil, 1)
+		rule := injector.On("", "/items", clienttest.ConnectionReset()).Times(2)
+
+		resp, err := injector.Client(client.WithRetryPolicy(time.Second, 3)).Get(context.Background(), server.URL+"/items")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+
+		assert.Equal(t, 2, rule.Injected())
+		assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+
+		_, err = injector.Client(client.WithRetryPolicy(time.Second, 1)).Get(context.Background(), server.URL+"/items")
+		require.NoError(t, err)
+
+		rule.Times(3)
+		_, err = injector.Client(client.WithRetryPolicy(time.Second, 1)).Get(context.Background(), server.URL+"/items")
+		assert.True(t, errors.Is(err, syscall.ECONNRESET))
+	})
+
+	t.Run("Answer scheduled requests with server errors", func(t *testing.T) {
+		defer reset()
+
+		injector := clienttest.NewFaultInjector(nil, 1)
+		injector.On("127.0.0.1", "/items/*", clienttest.ServerError(http.StatusServiceUnavailable)).Every(2)
+
+		testClient := injector.Client()
+
+		var statuses []int
+		for i := 0; i < 4; i++ {
+			resp, err := testClient.Get(context.Background(), server.URL+"/items/1")
+			require.NoError(t, err)
+			resp.Body.Close()
+			statuses = append(statuses, resp.StatusCode)
+		}
+
+		assert.Equal(t, []int{200, 503, 200, 503}, statuses)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+		// other paths are untouched
+		resp, err := testClient.Get(context.Background(), server.URL+"/other")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Inject faults by probability", func(t *testing.T) {
+		defer reset()
+
+		injector := clienttest.NewFaultInjector(nil, 1)
+		rule := injector.On("", "", clienttest.ServerError(http.StatusInternalServerError)).WithProbability(0.5)
+
+		testClient := injector.Client()
+		for i := 0; i < 100; i++ {
+			resp, err := testClient.Get(context.Background(), server.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		assert.InDelta(t, 50, rule.Injected(), 20)
+		assert.EqualValues(t, 100-rule.Injected(), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Turn faults on and off at runtime", func(t *testing.T) {
+		defer reset()
+
+		injector := clienttest.NewFaultInjector(nil, 1)
+		rule := injector.On("", "", clienttest.ServerError(http.StatusBadGateway))
+		testClient := injector.Client()
+
+		status := func() int {
+			resp, err := testClient.Get(context.Background(), server.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, http.StatusBadGateway, status())
+
+		rule.Disable()
+		assert.Equal(t, http.StatusOK, status())
+
+		rule.Enable()
+		injector.Disable()
+		assert.Equal(t, http.StatusOK, status())
+
+		injector.Enable()
+		assert.Equal(t, http.StatusBadGateway, status())
+
+		injector.Clear()
+		assert.Equal(t, http.StatusOK, status())
+	})
+
+	t.Run("Cut response bodies", func(t *testing.T) {
+		defer reset()
+
+		injector := clienttest.NewFaultInjector(nil, 1)
+		injector.On("", "", clienttest.PartialBody(10))
+
+		resp, err := injector.Client().Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+		assert.Len(t, body, 10)
+	})
+
+	t.Run("Fail requests with truncated headers", func(t *testing.T) {
+		defer reset()
+
+		injector := clienttest.NewFaultInjector(nil, 1)
+		injector.On("", "", clienttest.TruncatedHeaders())
+
+		_, err := injector.Client(client.WithRetryPolicy(time.Second, 2)).Get(context.Background(), server.URL)
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Time out attempts delayed by latency", func(t *testing.T) {
+		defer reset()
+
+		injector := clienttest.NewFaultInjector(nil, 1)
+		rule := injector.On("", "", clienttest.Latency(time.Second)).Times(1)
+
+		start := time.Now()
+		resp, err := injector.Client(client.WithRetryPolicy(50*time.Millisecond, 2)).Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, 1, rule.Injected())
+		assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Cancel slow bodies once the request timeout elapses", func(t *testing.T) {
+		defer reset()
+
+		injector := clienttest.NewFaultInjector(nil, 1)
+		injector.On("", "", clienttest.SlowBody(10, 20*time.Millisecond))
+
+		resp, err := injector.Client(client.WithRetryPolicy(100*time.Millisecond, 1)).Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		assert.NotEmpty(t, body)
+		assert.Less(t, len(body), 100)
+	})
+
+	t.Run("Time latency and slow bodies on the clock of the injector", func(t *testing.T) {
+		defer reset()
+
+		clock := clienttest.NewFakeClock(time.Now())
+		injector := clienttest.NewFaultInjector(nil, 1).WithClock(clock)
+		injector.On("", "", clienttest.Latency(time.Minute), clienttest.SlowBody(10, time.Second))
+
+		testClient := injector.Client(client.WithClock(clock), client.WithRetryPolicy(time.Hour, 1))
+
+		responses := make(chan *http.Response, 1)
+		go func() {
+			resp, err := testClient.Get(context.Background(), server.URL)
+			require.NoError(t, err)
+			responses <- resp
+		}()
+
+		// the timeout of the attempt and the latency
+		clock.BlockUntil(2)
+		clock.Advance(time.Minute)
+
+		resp := <-responses
+		defer resp.Body.Close()
+
+		read := make(chan int, 1)
+		go func() {
+			n, err := resp.Body.Read(make([]byte, 100))
+			require.NoError(t, err)
+			read <- n
+		}()
+
+		// the timeout of the attempt and the interval of the body
+		clock.BlockUntil(2)
+		clock.Advance(time.Second)
+
+		assert.Equal(t, 10, <-read)
+	})
+}
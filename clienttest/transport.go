@@ -0,0 +1,338 @@
+// Package clienttest provides test doubles for the users of the client package, so that they can test
+// how their code and the client behave, retries included, without real sockets. It also records and
+// replays real interactions, and injects faults in them.
+package clienttest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// TestingT is the subset of testing.TB used by the assertions
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Call is a request received by a MockTransport
+type Call struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// MockTransport is a programmable http.RoundTripper. Requests are answered by the first rule they match,
+// in the order the rules were added, requests matching no rule fail.
+type MockTransport struct {
+	mu    sync.Mutex
+	clock client.Clock
+	rules []*Rule
+	calls []Call
+}
+
+// NewMockTransport returns a MockTransport without any rule
+func NewMockTransport() *MockTransport {
+	return &MockTransport{}
+}
+
+// Client returns a client sending its requests through the transport
+func (m *MockTransport) Client(opts ...client.Option) *client.Client {
+	return client.New(append(opts[:len(opts):len(opts)], client.WithTransport(m))...)
+}
+
+// WithClock times the delays of the responses with clock, such as a FakeClock also given to the client, rather
+// than with the time package
+func (m *MockTransport) WithClock(clock client.Clock) *MockTransport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+	return m
+}
+
+// On adds a rule matching the requests with method and the URL path path, an empty method or path
+// matches any. The rule answers with the responses added to it, in sequence, the last response answers
+// all the requests that follow.
+func (m *MockTransport) On(method, path string) *Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := &Rule{transport: m, method: method, path: path}
+	m.rules = append(m.rules, r)
+
+	return r
+}
+
+// RoundTrip implements http.RoundTripper
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, Call{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Header: req.Header.Clone(),
+		Body:   body,
+	})
+
+	var s *step
+	for _, r := range m.rules {
+		if r.matches(req, body) {
+			s = r.next()
+			break
+		}
+	}
+	clock := m.clock
+	m.mu.Unlock()
+
+	if s == nil {
+		return nil, errors.Errorf("clienttest: no rule matches %s %s", req.Method, req.URL)
+	}
+
+	if s.delay > 0 {
+		if err := sleep(req.Context(), clock, s.delay); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.respond(req, body)
+}
+
+// Calls returns the requests received so far
+func (m *MockTransport) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Call(nil), m.calls...)
+}
+
+// AssertCalled checks that the transport received times requests with method and the URL path path
+func (m *MockTransport) AssertCalled(t TestingT, method, path string, times int) bool {
+	t.Helper()
+
+	var n int
+	for _, c := range m.Calls() {
+		if req, err := http.NewRequest(c.Method, c.URL, nil); err == nil && matchRoute(method, path, req) {
+			n++
+		}
+	}
+
+	if n != times {
+		t.Errorf("clienttest: %s %s called %d times, expected %d", method, path, n, times)
+		return false
+	}
+
+	return true
+}
+
+// AssertExpectations checks that every rule matched at least one request
+func (m *MockTransport) AssertExpectations(t TestingT) bool {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok := true
+	for _, r := range m.rules {
+		if r.calls == 0 {
+			t.Errorf("clienttest: %s %s never called", r.method, r.path)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// Rule matches requests and answers them with a sequence of responses
+type Rule struct {
+	transport *MockTransport
+	method    string
+	path      string
+	headers   http.Header
+	matchBody func([]byte) bool
+
+	steps []*step
+	calls int
+}
+
+// step is a response, or an error, of a rule
+type step struct {
+	status  int
+	header  http.Header
+	body    []byte
+	err     error
+	delay   time.Duration
+	handler func(*http.Request) (*http.Response, error)
+}
+
+// MatchHeader restricts the rule to the requests whose header key has value
+func (r *Rule) MatchHeader(key, value string) *Rule {
+	if r.headers == nil {
+		r.headers = make(http.Header)
+	}
+	r.headers.Add(key, value)
+
+	return r
+}
+
+// MatchBody restricts the rule to the requests whose body is body
+func (r *Rule) MatchBody(body string) *Rule {
+	return r.MatchBodyFunc(func(b []byte) bool {
+		return string(b) == body
+	})
+}
+
+// MatchBodyFunc restricts the rule to the requests whose body match reports true for
+func (r *Rule) MatchBodyFunc(match func(body []byte) bool) *Rule {
+	r.matchBody = match
+	return r
+}
+
+// Respond adds a response with status and body to the sequence of the rule
+func (r *Rule) Respond(status int, body string) *Rule {
+	r.steps = append(r.steps, &step{status: status, header: make(http.Header), body: []byte(body)})
+	return r
+}
+
+// RespondFunc adds a response computed by respond to the sequence of the rule
+func (r *Rule) RespondFunc(respond func(*http.Request) (*http.Response, error)) *Rule {
+	r.steps = append(r.steps, &step{handler: respond})
+	return r
+}
+
+// Fail adds an error, returned by the transport as if the connection failed, to the sequence of the rule
+func (r *Rule) Fail(err error) *Rule {
+	r.steps = append(r.steps, &step{err: err})
+	return r
+}
+
+// WithHeader sets a header of the last response added to the rule
+func (r *Rule) WithHeader(key, value string) *Rule {
+	s := r.last()
+	if s.header == nil {
+		s.header = make(http.Header)
+	}
+	s.header.Add(key, value)
+
+	return r
+}
+
+// Delay delays the last response added to the rule, unless the request is canceled first. The delay is timed
+// by the clock of the transport.
+func (r *Rule) Delay(d time.Duration) *Rule {
+	r.last().delay = d
+	return r
+}
+
+// Calls returns the number of requests the rule matched
+func (r *Rule) Calls() int {
+	r.transport.mu.Lock()
+	defer r.transport.mu.Unlock()
+
+	return r.calls
+}
+
+func (r *Rule) last() *step {
+	if len(r.steps) == 0 {
+		panic("clienttest: no response added to the rule")
+	}
+
+	return r.steps[len(r.steps)-1]
+}
+
+func (r *Rule) matches(req *http.Request, body []byte) bool {
+	if !matchRoute(r.method, r.path, req) {
+		return false
+	}
+
+	for key, values := range r.headers {
+		for _, v := range values {
+			if !contains(req.Header.Values(key), v) {
+				return false
+			}
+		}
+	}
+
+	return r.matchBody == nil || r.matchBody(body)
+}
+
+// next returns the step answering the request the rule just matched
+func (r *Rule) next() *step {
+	r.calls++
+	if len(r.steps) == 0 {
+		return &step{status: http.StatusOK}
+	}
+	if r.calls > len(r.steps) {
+		return r.steps[len(r.steps)-1]
+	}
+
+	return r.steps[r.calls-1]
+}
+
+func (s *step) respond(req *http.Request, body []byte) (*http.Response, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	if s.handler != nil {
+		// the handler may read the body of the request again, on a copy since round trippers must not
+		// modify the request
+		hReq := req.WithContext(req.Context())
+		hReq.Body = io.NopCloser(bytes.NewReader(body))
+
+		resp, err := s.handler(hReq)
+		if resp != nil && resp.Request == nil {
+			resp.Request = req
+		}
+
+		return resp, err
+	}
+
+	header := s.header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(s.body)))
+
+	return &http.Response{
+		Status:        strconv.Itoa(s.status) + " " + http.StatusText(s.status),
+		StatusCode:    s.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(s.body)),
+		ContentLength: int64(len(s.body)),
+		Request:       req,
+	}, nil
+}
+
+func matchRoute(method, path string, req *http.Request) bool {
+	return (method == "" || method == req.Method) && (path == "" || path == req.URL.Path)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
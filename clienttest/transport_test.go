@@ -0,0 +1,139 @@
+package clienttest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/clienttest"
+)
+
+// recorder records the errors reported by the assertions
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, format)
+}
+
+func TestMockTransport(t *testing.T) {
+	t.Run("Match requests by method, path, header and body", func(t *testing.T) {
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodPost, "/items").MatchHeader("X-Tenant", "a").MatchBody(`{"id":1}`).
+			Respond(http.StatusCreated, "created a").WithHeader("Location", "/items/1")
+		mock.On(http.MethodPost, "/items").Respond(http.StatusCreated, "created")
+
+		testClient := mock.Client()
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, "http://api/items", strings.NewReader(`{"id":1}`))
+		require.NoError(t, err)
+		req.Header.Set("X-Tenant", "a")
+
+		resp, err := testClient.Do(req)
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, "created a", string(body))
+		assert.Equal(t, "/items/1", resp.Header.Get("Location"))
+
+		resp, err = testClient.Post(context.Background(), "http://api/items", strings.NewReader(`{"id":2}`))
+		require.NoError(t, err)
+
+		body, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "created", string(body))
+		assert.True(t, mock.AssertCalled(t, http.MethodPost, "/items", 2))
+		assert.True(t, mock.AssertExpectations(t))
+	})
+
+	t.Run("Retry sequenced errors deterministically", func(t *testing.T) {
+		mock := clienttest.NewMockTransport()
+		rule := mock.On(http.MethodGet, "/flaky").
+			Fail(errors.New("connection reset")).
+			Fail(errors.New("connection reset")).
+			Respond(http.StatusOK, "ok")
+
+		testClient := mock.Client(client.WithRetryPolicy(time.Second, 3))
+
+		resp, err := testClient.Get(context.Background(), "http://api/flaky")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 3, rule.Calls())
+	})
+
+	t.Run("Time out delayed responses", func(t *testing.T) {
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodGet, "/slow").Respond(http.StatusOK, "slow").Delay(time.Minute).Respond(http.StatusOK, "fast")
+
+		testClient := mock.Client(client.WithRetryPolicy(50*time.Millisecond, 2))
+
+		resp, err := testClient.Get(context.Background(), "http://api/slow")
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, "fast", string(body))
+		assert.Len(t, mock.Calls(), 2)
+	})
+
+	t.Run("Time delays on the clock of the transport", func(t *testing.T) {
+		clock := clienttest.NewFakeClock(time.Now())
+		mock := clienttest.NewMockTransport().WithClock(clock)
+		mock.On(http.MethodGet, "/slow").Respond(http.StatusOK, "slow").Delay(time.Minute)
+
+		testClient := mock.Client(client.WithClock(clock), client.WithRetryPolicy(time.Hour, 1))
+
+		done := make(chan string, 1)
+		go func() {
+			resp, err := testClient.Get(context.Background(), "http://api/slow")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			done <- string(body)
+		}()
+
+		// the timeout of the attempt and the delay of the response
+		clock.BlockUntil(2)
+		clock.Advance(time.Minute)
+
+		assert.Equal(t, "slow", <-done)
+	})
+
+	t.Run("Fail requests matching no rule", func(t *testing.T) {
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodGet, "/never").Respond(http.StatusOK, "")
+
+		testClient := mock.Client(client.WithRetryPolicy(time.Second, 1))
+
+		resp, err := testClient.Get(context.Background(), "http://api/unknown") //nolint: bodyclose
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+
+		rec := &recorder{}
+		assert.False(t, mock.AssertExpectations(rec))
+		assert.False(t, mock.AssertCalled(rec, http.MethodGet, "/unknown", 2))
+		assert.Len(t, rec.errors, 2)
+	})
+}
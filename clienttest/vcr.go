@@ -0,0 +1,380 @@
+package clienttest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// Mode tells whether a Recorder records or replays its cassette
+type Mode int
+
+const (
+	// ModeRecord sends the requests through the real transport and records them to the cassette
+	ModeRecord Mode = iota
+	// ModeReplay answers the requests with the responses of the cassette
+	ModeReplay
+)
+
+// Redacted replaces the values of scrubbed headers in cassettes
+const Redacted = "[REDACTED]"
+
+// maxCassetteLineSize is the longest interaction a cassette may hold
+const maxCassetteLineSize = 64 << 20
+
+// RecordedRequest is a request of a cassette
+type RecordedRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// RecordedResponse is a response of a cassette
+type RecordedResponse struct {
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// Interaction is a request and its response, cassettes hold one interaction per line
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Matcher reports whether req, whose body is body, matches a recorded request
+type Matcher func(req *http.Request, body []byte, recorded RecordedRequest) bool
+
+// MatchMethod matches requests with the same method
+func MatchMethod(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL matches requests with the same URL
+func MatchURL(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+	return req.URL.String() == recorded.URL
+}
+
+// MatchBody matches requests with the same body
+func MatchBody(_ *http.Request, body []byte, recorded RecordedRequest) bool {
+	recordedBody, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+	return err == nil && bytes.Equal(body, recordedBody)
+}
+
+// MatchHeaders matches requests with the same values of the headers keys
+func MatchHeaders(keys ...string) Matcher {
+	return func(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+		for _, key := range keys {
+			if !equalValues(req.Header.Values(key), recorded.Header.Values(key)) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// MatchAll matches requests matched by all the matchers
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(req *http.Request, body []byte, recorded RecordedRequest) bool {
+		for _, m := range matchers {
+			if !m(req, body, recorded) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// RecorderOption configures a Recorder
+type RecorderOption interface {
+	apply(*Recorder)
+}
+
+type recorderOptionFunc func(*Recorder)
+
+func (f recorderOptionFunc) apply(r *Recorder) {
+	f(r)
+}
+
+// WithMatcher sets how replayed requests are matched with the recorded ones, by default requests are
+// matched by method and URL
+func WithMatcher(matcher Matcher) RecorderOption {
+	return recorderOptionFunc(func(r *Recorder) {
+		r.matcher = matcher
+	})
+}
+
+// WithScrubbedHeaders redacts the values of the headers keys, of requests and responses, in the cassette.
+// Authorization, Proxy-Authorization, Cookie and Set-Cookie are always scrubbed.
+func WithScrubbedHeaders(keys ...string) RecorderOption {
+	return recorderOptionFunc(func(r *Recorder) {
+		for _, k := range keys {
+			r.scrubbed = append(r.scrubbed, http.CanonicalHeaderKey(k))
+		}
+	})
+}
+
+// WithStrict fails replayed requests matching no recorded request, instead of sending them through the
+// real transport
+func WithStrict() RecorderOption {
+	return recorderOptionFunc(func(r *Recorder) {
+		r.strict = true
+	})
+}
+
+// WithRealTransport sets the transport requests are recorded from, http.DefaultTransport by default
+func WithRealTransport(rt http.RoundTripper) RecorderOption {
+	return recorderOptionFunc(func(r *Recorder) {
+		r.real = rt
+	})
+}
+
+// Recorder is an http.RoundTripper recording interactions to a cassette, a JSON lines file, or replaying
+// them from it, so that tests run offline and deterministically. Recorded interactions are replayed in
+// order, each once, after which the last matching interaction answers the requests that follow.
+type Recorder struct {
+	mode     Mode
+	matcher  Matcher
+	scrubbed []string
+	strict   bool
+	real     http.RoundTripper
+
+	mu           sync.Mutex
+	file         *os.File
+	encoder      *client.JSONLinesEncoder
+	interactions []Interaction
+	replayed     []bool
+}
+
+// NewRecorder returns a recorder of the cassette at path: in ModeRecord the cassette is created, or
+// truncated, in ModeReplay it is loaded
+func NewRecorder(path string, mode Mode, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		mode:     mode,
+		matcher:  MatchAll(MatchMethod, MatchURL),
+		scrubbed: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+		real:     http.DefaultTransport,
+	}
+	for _, o := range opts {
+		o.apply(r)
+	}
+
+	if mode == ModeRecord {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating cassette")
+		}
+		r.file = f
+		r.encoder = client.NewJSONLinesEncoder(f)
+
+		return r, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening cassette")
+	}
+	defer f.Close()
+
+	interactions := client.StreamJSONLines[Interaction](f, maxCassetteLineSize)
+	for interactions.Next() {
+		r.interactions = append(r.interactions, interactions.Value())
+	}
+	if err := interactions.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading cassette")
+	}
+	r.replayed = make([]bool, len(r.interactions))
+
+	return r, nil
+}
+
+// Client returns a client sending its requests through the recorder
+func (r *Recorder) Client(opts ...client.Option) *client.Client {
+	return client.New(append(opts[:len(opts):len(opts)], client.WithTransport(r))...)
+}
+
+// Close closes the cassette being recorded
+func (r *Recorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+
+	return r.file.Close()
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.mode == ModeReplay {
+		if i, ok := r.match(req, body); ok {
+			return replay(req, i.Response)
+		}
+
+		if r.strict {
+			return nil, errors.Errorf("clienttest: no recorded interaction matches %s %s", req.Method, req.URL)
+		}
+	}
+
+	rReq := req.WithContext(req.Context())
+	rReq.Body = io.NopCloser(bytes.NewReader(body))
+	if body == nil {
+		rReq.Body = nil
+	}
+
+	resp, err := r.real.RoundTrip(rReq)
+	if err != nil || r.mode != ModeRecord {
+		return resp, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	if err := r.record(req, body, resp, respBody); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// match returns the first interaction matching req not replayed yet, or the last matching one
+func (r *Recorder) match(req *http.Request, body []byte) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := -1
+	for i, interaction := range r.interactions {
+		if !r.matcher(req, body, interaction.Request) {
+			continue
+		}
+
+		if !r.replayed[i] {
+			r.replayed[i] = true
+			return interaction, true
+		}
+		last = i
+	}
+
+	if last < 0 {
+		return Interaction{}, false
+	}
+
+	return r.interactions[last], true
+}
+
+func (r *Recorder) record(req *http.Request, body []byte, resp *http.Response, respBody []byte) error {
+	i := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: r.scrub(req.Header),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.scrub(resp.Header),
+		},
+	}
+	i.Request.Body, i.Request.BodyEncoding = encodeBody(body)
+	i.Response.Body, i.Response.BodyEncoding = encodeBody(respBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.encoder.Encode(i); err != nil {
+		return errors.Wrap(err, "error writing cassette")
+	}
+
+	return nil
+}
+
+func (r *Recorder) scrub(header http.Header) http.Header {
+	scrubbed := header.Clone()
+	for _, k := range r.scrubbed {
+		if values := scrubbed[k]; len(values) > 0 {
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+
+	return scrubbed
+}
+
+func replay(req *http.Request, recorded RecordedResponse) (*http.Response, error) {
+	body, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding recorded body")
+	}
+
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(recorded.StatusCode) + " " + http.StatusText(recorded.StatusCode),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// encodeBody keeps text bodies readable in cassettes, other bodies are base64 encoded
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+
+	return []byte(body), nil
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
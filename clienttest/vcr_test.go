@@ -0,0 +1,157 @@
+package clienttest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zackwwu/http-client-go/clienttest"
+)
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	return string(body)
+}
+
+func TestRecorder(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Request", string(rune('0'+n)))
+		switch r.URL.Path {
+		case "/binary":
+			_, _ = w.Write([]byte{0xff, 0x00, 0xfe})
+		default:
+			_, _ = w.Write([]byte(r.Method + " " + string(body)))
+		}
+	}))
+	defer server.Close()
+
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	// records the cassette used by the subtests
+	rec, err := clienttest.NewRecorder(cassette, clienttest.ModeRecord, clienttest.WithScrubbedHeaders("x-api-key"))
+	require.NoError(t, err)
+
+	recordClient := rec.Client()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/items", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Api-Key", "secret")
+
+	resp, err := recordClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "GET ", readBody(t, resp))
+
+	resp, err = recordClient.Post(context.Background(), server.URL+"/items", strings.NewReader("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "POST a", readBody(t, resp))
+
+	resp, err = recordClient.Post(context.Background(), server.URL+"/items", strings.NewReader("b"))
+	require.NoError(t, err)
+	assert.Equal(t, "POST b", readBody(t, resp))
+
+	resp, err = recordClient.Get(context.Background(), server.URL+"/binary")
+	require.NoError(t, err)
+	assert.Equal(t, string([]byte{0xff, 0x00, 0xfe}), readBody(t, resp))
+
+	require.NoError(t, rec.Close())
+	require.EqualValues(t, 4, atomic.LoadInt32(&requests))
+
+	t.Run("Record scrubbed interactions as JSON lines", func(t *testing.T) {
+		content, err := os.ReadFile(cassette)
+		require.NoError(t, err)
+
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		assert.Len(t, lines, 4)
+		assert.NotContains(t, string(content), "secret")
+		assert.Contains(t, lines[0], `"Authorization":["[REDACTED]"]`)
+		assert.Contains(t, lines[0], `"X-Api-Key":["[REDACTED]"]`)
+		assert.Contains(t, lines[0], `"Set-Cookie":["[REDACTED]"]`)
+		assert.Contains(t, lines[3], `"body_encoding":"base64"`)
+	})
+
+	t.Run("Replay interactions in order without the server", func(t *testing.T) {
+		rep, err := clienttest.NewRecorder(cassette, clienttest.ModeReplay, clienttest.WithStrict())
+		require.NoError(t, err)
+		defer rep.Close()
+
+		replayClient := rep.Client()
+
+		resp, err := replayClient.Post(context.Background(), server.URL+"/items", strings.NewReader("x"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "POST a", readBody(t, resp))
+		assert.Equal(t, "2", resp.Header.Get("X-Request"))
+
+		resp, err = replayClient.Post(context.Background(), server.URL+"/items", strings.NewReader("x"))
+		require.NoError(t, err)
+		assert.Equal(t, "POST b", readBody(t, resp))
+
+		// the last matching interaction answers once all were replayed
+		resp, err = replayClient.Post(context.Background(), server.URL+"/items", strings.NewReader("x"))
+		require.NoError(t, err)
+		assert.Equal(t, "POST b", readBody(t, resp))
+
+		resp, err = replayClient.Get(context.Background(), server.URL+"/binary")
+		require.NoError(t, err)
+		assert.Equal(t, string([]byte{0xff, 0x00, 0xfe}), readBody(t, resp))
+
+		assert.EqualValues(t, 4, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Match requests by body", func(t *testing.T) {
+		rep, err := clienttest.NewRecorder(cassette, clienttest.ModeReplay, clienttest.WithStrict(),
+			clienttest.WithMatcher(clienttest.MatchAll(clienttest.MatchMethod, clienttest.MatchURL, clienttest.MatchBody)))
+		require.NoError(t, err)
+
+		replayClient := rep.Client()
+
+		resp, err := replayClient.Post(context.Background(), server.URL+"/items", strings.NewReader("b"))
+		require.NoError(t, err)
+		assert.Equal(t, "POST b", readBody(t, resp))
+
+		_, err = replayClient.Post(context.Background(), server.URL+"/items", strings.NewReader("c"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no recorded interaction matches POST")
+
+		assert.EqualValues(t, 4, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Send unmatched requests to the real transport unless strict", func(t *testing.T) {
+		rep, err := clienttest.NewRecorder(cassette, clienttest.ModeReplay)
+		require.NoError(t, err)
+
+		resp, err := rep.Client().Get(context.Background(), server.URL+"/other")
+		require.NoError(t, err)
+		assert.Equal(t, "GET ", readBody(t, resp))
+
+		assert.EqualValues(t, 5, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Fail on a corrupted cassette", func(t *testing.T) {
+		corrupted := filepath.Join(t.TempDir(), "corrupted.jsonl")
+		require.NoError(t, os.WriteFile(corrupted, []byte("{\"request\":{}}\n{\n"), 0o600))
+
+		_, err := clienttest.NewRecorder(corrupted, clienttest.ModeReplay)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "JSON line 2")
+	})
+}
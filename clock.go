@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/kamilsk/retry/v5/backoff"
+	"github.com/kamilsk/retry/v5/jitter"
+	"github.com/kamilsk/retry/v5/strategy"
+	"github.com/pkg/errors"
+)
+
+// Clock tells the time and runs timers. The client times the backoff of the standard retry policy, the
+// timeout of attempts, the reconnections and idle timeout of event streams, and the freshness of cached
+// responses with it, so that tests can replace it by a fake clock to control time.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a timer sending the time on its channel once d elapsed
+	NewTimer(d time.Duration) Timer
+	// AfterFunc returns a timer calling f once d elapsed, the channel of the timer is nil
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer of a Clock, as time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// realClock is the Clock of the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
+
+// clockOf returns the clock given by WithClock, the real clock by default
+func clockOf(opts options) Clock {
+	if opts.clock != nil {
+		return opts.clock
+	}
+
+	return realClock{}
+}
+
+// withTimeout is context.WithTimeout timed by clock
+func withTimeout(ctx context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(realClock); ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+
+	cCtx, cancel := context.WithCancelCause(ctx)
+	timer := clock.AfterFunc(timeout, func() {
+		cancel(context.DeadlineExceeded)
+	})
+
+	return timeoutContext{cCtx}, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// timeoutContext is canceled by the timer of a clock, it has no deadline since the time of the clock may
+// not be the real time
+type timeoutContext struct {
+	context.Context
+}
+
+func (c timeoutContext) Err() error {
+	err := c.Context.Err()
+	if err != nil && errors.Is(context.Cause(c.Context), context.DeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
+
+// standardBackOff returns the durations of StandardBackOffStrategy
+func standardBackOff(expFactor time.Duration, generator *rand.Rand, stdDeviation float64) func(attempt uint) time.Duration {
+	algorithm := backoff.BinaryExponential(expFactor)
+	transformation := jitter.NormalDistribution(generator, stdDeviation)
+
+	return func(attempt uint) time.Duration {
+		return transformation(algorithm(attempt))
+	}
+}
+
+// backOffStrategy waits before each attempt following the first, for the duration given by backOff, on clock
+func backOffStrategy(clock Clock, backOff func(attempt uint) time.Duration) strategy.Strategy {
+	return func(breaker strategy.Breaker, attempt uint, _ error) bool {
+		if attempt == 0 {
+			return true
+		}
+
+		timer := clock.NewTimer(backOff(attempt))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C():
+			return true
+		case <-breaker.Done():
+			return false
+		}
+	}
+}
+
+// strategies returns the strategies of the retry loop of the policy, the back off waits on clock
+func (p *retryPolicy) strategies(clock Clock) []strategy.Strategy {
+	strategies := make([]strategy.Strategy, 0, len(p.retryStrategies)+2)
+	strategies = append(strategies, stopOnPermanentError)
+	strategies = append(strategies, p.retryStrategies...)
+	if p.backOff != nil {
+		strategies = append(strategies, backOffStrategy(clock, p.backOff))
+	}
+
+	return strategies
+}
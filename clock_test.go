@@ -0,0 +1,102 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/clienttest"
+)
+
+// blockUntilCanceled answers requests once they are canceled, as a server that doesn't respond
+func blockUntilCanceled(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestClock(t *testing.T) {
+	t.Run("Time out attempts and back off on the clock", func(t *testing.T) {
+		clock := clienttest.NewFakeClock(time.Now())
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodGet, "/").RespondFunc(blockUntilCanceled).RespondFunc(blockUntilCanceled).
+			Respond(http.StatusOK, "ok")
+
+		testClient := mock.Client(client.WithClock(clock), client.WithStandardRetryPolicy(10*time.Second, 3))
+
+		type result struct {
+			resp *http.Response
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			resp, err := testClient.Get(context.Background(), "http://api/")
+			done <- result{resp, err}
+		}()
+
+		start := clock.Now()
+		for i := 0; i < 2; i++ {
+			// the timeout of the attempt
+			clock.BlockUntil(1)
+			clock.Advance(10 * time.Second)
+
+			// the back off before the next attempt
+			clock.BlockUntil(1)
+			clock.Advance(time.Second)
+		}
+
+		r := <-done
+		require.NoError(t, r.err)
+		defer r.resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, r.resp.StatusCode)
+		assert.Len(t, mock.Calls(), 3)
+		assert.Equal(t, 22*time.Second, clock.Now().Sub(start))
+	})
+
+	t.Run("Fail attempts timed out on the clock with context.DeadlineExceeded", func(t *testing.T) {
+		clock := clienttest.NewFakeClock(time.Now())
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodGet, "/").RespondFunc(blockUntilCanceled)
+
+		testClient := mock.Client(client.WithClock(clock), client.WithRetryPolicy(time.Minute, 1))
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := testClient.Get(context.Background(), "http://api/") //nolint: bodyclose
+			done <- err
+		}()
+
+		clock.BlockUntil(1)
+		clock.Advance(59 * time.Second)
+		assert.Equal(t, 1, clock.Timers())
+
+		clock.Advance(time.Second)
+		assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+	})
+
+	t.Run("Expire cached responses on the clock", func(t *testing.T) {
+		clock := clienttest.NewFakeClock(time.Now())
+		mock := clienttest.NewMockTransport()
+		mock.On(http.MethodGet, "/").Respond(http.StatusOK, "content").WithHeader("Cache-Control", "max-age=60")
+
+		testClient := mock.Client(client.WithClock(clock), client.WithCache(client.NewMemoryCache(1<<20)))
+
+		resp, _ := getCached(t, testClient, "http://api/", nil)
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+
+		clock.Advance(30 * time.Second)
+		resp, _ = getCached(t, testClient, "http://api/", nil)
+		assert.Equal(t, client.CacheHit, resp.Header.Get(client.CacheStatusHeader))
+		assert.Equal(t, "30", resp.Header.Get("Age"))
+
+		clock.Advance(31 * time.Second)
+		resp, _ = getCached(t, testClient, "http://api/", nil)
+		assert.Equal(t, client.CacheMiss, resp.Header.Get(client.CacheStatusHeader))
+
+		assert.Len(t, mock.Calls(), 2)
+	})
+}
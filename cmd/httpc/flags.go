@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	"github.com/kamilsk/retry/v5/strategy"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/pkg/errors"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// clientFlags are the flags configuring the client, shared by the commands
+type clientFlags struct {
+	timeout  time.Duration
+	retries  uint
+	backoff  string
+	mockSpan bool
+}
+
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	fs.DurationVar(&f.timeout, "timeout", 5*time.Second, "timeout of each attempt")
+	fs.UintVar(&f.retries, "retries", 10, "maximum number of attempts")
+	fs.StringVar(&f.backoff, "backoff", "standard", "back off between attempts: standard, none or a constant duration")
+	fs.BoolVar(&f.mockSpan, "mock-span", false, "inject the span of a mock tracer in the request headers, as Mockpfx-Ids-* headers rather than the format of a real tracer")
+}
+
+// options returns the client options set by the flags
+func (f *clientFlags) options() ([]client.Option, error) {
+	var opts []client.Option
+
+	switch f.backoff {
+	case "standard":
+		opts = append(opts, client.WithStandardRetryPolicy(f.timeout, f.retries))
+	case "none":
+		opts = append(opts, client.WithRetryPolicy(f.timeout, f.retries))
+	default:
+		d, err := time.ParseDuration(f.backoff)
+		if err != nil {
+			return nil, errors.Errorf("invalid -backoff %q: expected standard, none or a duration", f.backoff)
+		}
+		opts = append(opts, client.WithRetryPolicy(f.timeout, f.retries, strategy.Wait(d)))
+	}
+
+	if f.mockSpan {
+		// the mock tracer records spans in memory and injects them in its own header format, which shows
+		// that the span is propagated but not the headers a real tracer would send
+		opentracing.SetGlobalTracer(mocktracer.New())
+		opts = append(opts, client.WithTracingOptions(true, "httpc"), client.WithSpanCarrierInjected())
+	}
+
+	return opts, nil
+}
+
+// headerFlags collects the values of a repeated header flag
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return errors.Errorf("invalid header %q: expected Key: Value", value)
+	}
+	*h = append(*h, value)
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+
+	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/load"
+)
+
+// runLoad drives a target with requests, see package load
+func runLoad(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("httpc load", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: httpc load [flags] URL")
+		fs.PrintDefaults()
+	}
+
+	var (
+		cf          clientFlags
+		headers     headerFlags
+		method      = fs.String("X", "", "request method, GET or POST when a body is given by default")
+		data        = fs.String("d", "", "request body, @path reads it from a file and @- from stdin")
+		rate        = fs.Float64("rate", 0, "requests per second, requests are sent back to back by the workers when 0")
+		concurrency = fs.Int("concurrency", 0, "number of workers, 1 by default, or with -rate the maximum number of requests in flight, unbounded by default")
+		duration    = fs.Duration("duration", 0, "duration of the load, 0 for no limit, -duration or -n is required")
+		requests    = fs.Int64("n", 0, "number of requests to send, 0 for no limit, -duration or -n is required")
+		jsonOut     = fs.Bool("json", false, "print the report as JSON")
+	)
+	cf.register(fs)
+	fs.Var(&headers, "H", "request header as Key: Value, repeatable")
+
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	opts, err := cf.options()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	body, err := readData(*data, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	// the request is checked once, before the load starts
+	if _, err := newRequest(*method, fs.Arg(0), headers, body); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	newLoadRequest := func(ctx context.Context) (*http.Request, error) {
+		req, err := newRequest(*method, fs.Arg(0), headers, body)
+		if err != nil {
+			return nil, err
+		}
+
+		return req.WithContext(ctx), nil
+	}
+
+	report, err := load.Run(context.Background(), client.New(opts...), newLoadRequest,
+		load.WithRate(*rate), load.WithConcurrency(*concurrency), load.WithDuration(*duration), load.WithRequests(*requests))
+	if err == load.ErrNoLimit {
+		fmt.Fprintln(stderr, "httpc: -duration or -n is required")
+		return exitUsage
+	}
+
+	if report != nil {
+		if *jsonOut {
+			enc := json.NewEncoder(stdout)
+			enc.SetEscapeHTML(false)
+			_ = enc.Encode(report)
+		} else {
+			_, _ = report.WriteTo(stdout)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintln(stderr, "httpc:", err)
+		return exitFailure
+	}
+
+	return exitOK
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Report the load as text", func(t *testing.T) {
+		server := newEchoServer(t, 0)
+
+		code, stdout, _ := runHttpc("", "load", "-n", "10", "-concurrency", "2", server.URL)
+		assert.Equal(t, exitOK, code)
+		assert.Contains(t, stdout, "requests    10 in ")
+		assert.Contains(t, stdout, "statuses    200: 10\n")
+	})
+
+	t.Run("Report the load as JSON", func(t *testing.T) {
+		server := newEchoServer(t, 0)
+
+		code, stdout, _ := runHttpc("", "load", "-json", "-rate", "50", "-duration", "100ms", "-d", "body", server.URL)
+		assert.Equal(t, exitOK, code)
+
+		var report struct {
+			Requests int64              `json:"requests"`
+			Statuses map[string]int64   `json:"statuses"`
+			Latency  map[string]float64 `json:"latency_ms"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(stdout), &report))
+		assert.InDelta(t, 5, report.Requests, 2)
+		assert.Equal(t, report.Requests, report.Statuses["200"])
+		assert.Contains(t, report.Latency, "p99")
+	})
+
+	t.Run("Keep the rate whatever the latency", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		t.Cleanup(server.Close)
+
+		code, stdout, _ := runHttpc("", "load", "-json", "-rate", "50", "-duration", "200ms", server.URL)
+		assert.Equal(t, exitOK, code)
+
+		var report struct {
+			Requests int64 `json:"requests"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(stdout), &report))
+		// a single request in flight would allow two requests at most before the duration elapses
+		assert.Greater(t, report.Requests, int64(2))
+		assert.LessOrEqual(t, report.Requests, int64(11))
+	})
+
+	t.Run("Require a limit", func(t *testing.T) {
+		code, _, stderr := runHttpc("", "load", "http://localhost")
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr, "-duration or -n is required")
+
+		code, _, stderr = runHttpc("", "load", "-duration", "0", "http://localhost")
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr, "-duration or -n is required")
+	})
+}
@@ -0,0 +1,45 @@
+// Command httpc sends HTTP requests through the client of this module, with its retry policy, so that the
+// behavior of services using the client can be reproduced from the shell.
+//
+// Usage:
+//
+//	httpc [flags] URL
+//	httpc replay [flags] FILE
+//	httpc load [flags] URL
+//
+// The first form sends a request, replay sends the requests described in a JSON lines file, see package
+// replay, and load drives URL with requests and reports their latency, see package load. Run httpc -h, or
+// httpc replay -h and httpc load -h, for the flags.
+package main
+
+import (
+	"io"
+	"os"
+)
+
+const (
+	exitOK = iota
+	exitFailure
+	exitUsage
+
+	// exitHTTPError is returned by -fail for responses with a status of 400 or above, as curl does
+	exitHTTPError = 22
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs httpc with the command line arguments args and returns its exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "replay":
+			return runReplay(args[1:], stdin, stdout, stderr)
+		case "load":
+			return runLoad(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	return runRequest(args, stdin, stdout, stderr)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEchoServer answers requests with their method, X-Test header and body, the connections of the first
+// failures requests are closed without a response
+func newEchoServer(t *testing.T, failures int32) *httptest.Server {
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		_, _ = w.Write([]byte(r.Method + " " + r.Header.Get("X-Test") + " " + string(body)))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func runHttpc(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRequest(t *testing.T) {
+	t.Run("Send a request with headers and a body", func(t *testing.T) {
+		server := newEchoServer(t, 0)
+
+		code, stdout, _ := runHttpc("", "-H", "X-Test: a", "-d", "body", server.URL)
+		assert.Equal(t, exitOK, code)
+		assert.Equal(t, "POST a body", stdout)
+
+		path := filepath.Join(t.TempDir(), "body")
+		require.NoError(t, os.WriteFile(path, []byte("from file"), 0o600))
+
+		code, stdout, _ = runHttpc("", "-X", "PUT", "-d", "@"+path, server.URL)
+		assert.Equal(t, exitOK, code)
+		assert.Equal(t, "PUT  from file", stdout)
+
+		code, stdout, _ = runHttpc("from stdin", "-d", "@-", server.URL)
+		assert.Equal(t, exitOK, code)
+		assert.Equal(t, "POST  from stdin", stdout)
+	})
+
+	t.Run("Print attempts verbosely", func(t *testing.T) {
+		server := newEchoServer(t, 1)
+
+		code, stdout, stderr := runHttpc("", "-v", "-i", "-backoff", "none", "-retries", "2", server.URL+"/path")
+		assert.Equal(t, exitOK, code)
+		assert.Contains(t, stdout, "HTTP/1.1 200 OK\n")
+		assert.True(t, strings.HasSuffix(stdout, "\nGET  "))
+
+		assert.Contains(t, stderr, "> GET /path HTTP/1.1\n")
+		assert.Contains(t, stderr, "* attempt 1 failed in ")
+		assert.Contains(t, stderr, "* attempt 2: 200 in ")
+		assert.Contains(t, stderr, "< HTTP/1.1 200 OK\n")
+		assert.Contains(t, stderr, "* 2 attempts in ")
+	})
+
+	t.Run("Print the result as JSON", func(t *testing.T) {
+		server := newEchoServer(t, 2)
+
+		code, stdout, _ := runHttpc("", "-json", "-retries", "3", server.URL)
+		assert.Equal(t, exitOK, code)
+
+		var result requestResult
+		require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+		assert.Equal(t, http.MethodGet, result.Method)
+		assert.Equal(t, http.StatusOK, result.Status)
+		assert.Equal(t, "GET  ", result.Body)
+		require.Len(t, result.Attempts, 3)
+		assert.NotEmpty(t, result.Attempts[0].Error)
+		assert.NotEmpty(t, result.Attempts[1].Error)
+		assert.Equal(t, http.StatusOK, result.Attempts[2].Status)
+		assert.Empty(t, result.Error)
+	})
+
+	t.Run("Fail when attempts are exhausted", func(t *testing.T) {
+		server := newEchoServer(t, 2)
+
+		code, stdout, _ := runHttpc("", "-json", "-retries", "2", "-backoff", "1ms", server.URL)
+		assert.Equal(t, exitFailure, code)
+
+		var result requestResult
+		require.NoError(t, json.Unmarshal([]byte(stdout), &result))
+		assert.Len(t, result.Attempts, 2)
+		assert.NotEmpty(t, result.Error)
+		assert.Zero(t, result.Status)
+	})
+
+	t.Run("Fail on HTTP errors with -fail", func(t *testing.T) {
+		server := newEchoServer(t, 0)
+
+		code, _, _ := runHttpc("", server.URL+"/missing")
+		assert.Equal(t, exitOK, code)
+
+		code, _, _ = runHttpc("", "-fail", server.URL+"/missing")
+		assert.Equal(t, exitHTTPError, code)
+	})
+
+	t.Run("Inject a mock span in the request headers", func(t *testing.T) {
+		server := newEchoServer(t, 0)
+
+		code, _, stderr := runHttpc("", "-v", "-mock-span", server.URL)
+		assert.Equal(t, exitOK, code)
+		assert.Contains(t, stderr, "> Mockpfx-Ids-Traceid: ")
+	})
+
+	t.Run("Reject invalid flags", func(t *testing.T) {
+		code, _, _ := runHttpc("")
+		assert.Equal(t, exitUsage, code)
+
+		code, _, stderr := runHttpc("", "-backoff", "sometimes", "http://localhost")
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr, "invalid -backoff")
+
+		code, _, _ = runHttpc("", "-H", "no colon", "http://localhost")
+		assert.Equal(t, exitUsage, code)
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/replay"
+)
+
+// runReplay sends the requests of a JSON lines file, see package replay
+func runReplay(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("httpc replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: httpc replay [flags] FILE")
+		fmt.Fprintln(stderr, "FILE holds a request spec per line, - reads the specs from stdin")
+		fs.PrintDefaults()
+	}
+
+	var (
+		cf          clientFlags
+		concurrency = fs.Int("concurrency", 1, "number of requests sent at once")
+		rate        = fs.Float64("rate", 0, "maximum number of requests per second, 0 for no limit")
+		output      = fs.String("o", "", "file the results are written to, stdout by default")
+	)
+	cf.register(fs)
+
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	opts, err := cf.options()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	specs := stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, "httpc:", err)
+			return exitUsage
+		}
+		defer f.Close()
+		specs = f
+	}
+
+	results := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(stderr, "httpc:", err)
+			return exitUsage
+		}
+		defer f.Close()
+		results = f
+	}
+
+	summary, err := replay.Run(context.Background(), client.New(opts...), specs, results,
+		replay.WithConcurrency(*concurrency), replay.WithRate(*rate))
+	fmt.Fprintln(stderr, formatSummary(summary))
+	if err != nil {
+		fmt.Fprintln(stderr, "httpc:", err)
+		return exitFailure
+	}
+
+	return exitOK
+}
+
+// formatSummary formats the summary of a replay as "3 requests, 1 failed, 200: 2"
+func formatSummary(summary replay.Summary) string {
+	statuses := make([]int, 0, len(summary.Statuses))
+	for status := range summary.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	parts := []string{
+		fmt.Sprintf("%d requests", summary.Requests),
+		fmt.Sprintf("%d failed", summary.Failed),
+	}
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%d: %d", status, summary.Statuses[status]))
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay(t *testing.T) {
+	t.Run("Replay specs from stdin", func(t *testing.T) {
+		server := newEchoServer(t, 0)
+
+		specs := `{"id":"a","url":"` + server.URL + `"}` + "\n" + `{"url":"` + server.URL + `/missing"}` + "\n"
+
+		code, stdout, stderr := runHttpc(specs, "replay", "-concurrency", "2", "-")
+		assert.Equal(t, exitOK, code)
+
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"id":"a"`)
+		assert.Contains(t, lines[1], `"status":404`)
+		assert.Equal(t, "2 requests, 0 failed, 200: 1, 404: 1\n", stderr)
+	})
+
+	t.Run("Replay specs from a file to a file", func(t *testing.T) {
+		server := newEchoServer(t, 0)
+
+		dir := t.TempDir()
+		input := filepath.Join(dir, "specs.jsonl")
+		output := filepath.Join(dir, "results.jsonl")
+		require.NoError(t, os.WriteFile(input, []byte(`{"url":"`+server.URL+`"}`+"\n"), 0o600))
+
+		code, stdout, _ := runHttpc("", "replay", "-o", output, input)
+		assert.Equal(t, exitOK, code)
+		assert.Empty(t, stdout)
+
+		results, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Contains(t, string(results), `"status":200`)
+	})
+
+	t.Run("Fail on invalid specs", func(t *testing.T) {
+		code, _, stderr := runHttpc("{\n", "replay", "-")
+		assert.Equal(t, exitFailure, code)
+		assert.Contains(t, stderr, "error reading specs")
+
+		code, _, _ = runHttpc("", "replay")
+		assert.Equal(t, exitUsage, code)
+	})
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// attemptResult is an attempt of a request in the JSON output
+type attemptResult struct {
+	Attempt    int     `json:"attempt"`
+	Status     int     `json:"status,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// requestResult is the JSON output of a request
+type requestResult struct {
+	Method       string          `json:"method"`
+	URL          string          `json:"url"`
+	Status       int             `json:"status,omitempty"`
+	Header       http.Header     `json:"header,omitempty"`
+	Body         string          `json:"body,omitempty"`
+	BodyEncoding string          `json:"body_encoding,omitempty"`
+	Attempts     []attemptResult `json:"attempts"`
+	DurationMS   float64         `json:"duration_ms"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// runRequest sends the request described by args
+func runRequest(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("httpc", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: httpc [flags] URL")
+		fs.PrintDefaults()
+	}
+
+	var (
+		cf       clientFlags
+		headers  headerFlags
+		method   = fs.String("X", "", "request method, GET or POST when a body is given by default")
+		data     = fs.String("d", "", "request body, @path reads it from a file and @- from stdin")
+		verbose  = fs.Bool("v", false, "print the request, the attempts and the response headers to stderr")
+		include  = fs.Bool("i", false, "print the response status and headers before the body")
+		jsonOut  = fs.Bool("json", false, "print the result as JSON")
+		failHTTP = fs.Bool("fail", false, fmt.Sprintf("exit with %d when the response status is 400 or above", exitHTTPError))
+	)
+	cf.register(fs)
+	fs.Var(&headers, "H", "request header as Key: Value, repeatable")
+
+	if err := fs.Parse(args); err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	opts, err := cf.options()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	body, err := readData(*data, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	req, err := newRequest(*method, fs.Arg(0), headers, body)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	result := requestResult{Method: req.Method, URL: req.URL.String(), Attempts: []attemptResult{}}
+	opts = append(opts, client.WithAttemptObserver(func(info client.AttemptInfo) {
+		attempt := attemptResult{
+			Attempt:    info.Attempt,
+			DurationMS: milliseconds(info.Duration),
+		}
+		if info.Err != nil {
+			attempt.Error = info.Err.Error()
+		} else {
+			attempt.Status = info.Response.StatusCode
+		}
+		result.Attempts = append(result.Attempts, attempt)
+
+		if *verbose {
+			if info.Attempt == 1 {
+				printRequest(stderr, info.Request)
+			}
+			printAttempt(stderr, attempt)
+		}
+	}))
+
+	start := time.Now()
+	resp, err := client.New(opts...).Do(req)
+
+	var respBody []byte
+	if err == nil {
+		respBody, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+	}
+	result.DurationMS = milliseconds(time.Since(start))
+
+	if resp != nil {
+		result.Status = resp.StatusCode
+		result.Header = resp.Header
+		result.Body, result.BodyEncoding = encodeBody(respBody)
+		if *verbose {
+			printResponseHeader(stderr, "< ", resp)
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if *verbose {
+		fmt.Fprintf(stderr, "* %d attempts in %.1fms\n", len(result.Attempts), result.DurationMS)
+	}
+
+	if *jsonOut {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		_ = enc.Encode(result)
+	} else if resp != nil {
+		if *include {
+			printResponseHeader(stdout, "", resp)
+			fmt.Fprintln(stdout)
+		}
+		_, _ = stdout.Write(respBody)
+	}
+
+	switch {
+	case err != nil:
+		if !*jsonOut {
+			fmt.Fprintln(stderr, "httpc:", err)
+		}
+		return exitFailure
+	case *failHTTP && resp.StatusCode >= http.StatusBadRequest:
+		return exitHTTPError
+	default:
+		return exitOK
+	}
+}
+
+// readData returns the body given by the -d flag
+func readData(data string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case data == "@-":
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading body from stdin")
+		}
+		return b, nil
+	case strings.HasPrefix(data, "@"):
+		b, err := os.ReadFile(data[1:])
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading body")
+		}
+		return b, nil
+	case data != "":
+		return []byte(data), nil
+	default:
+		return nil, nil
+	}
+}
+
+// newRequest returns a request whose body can be sent again by each attempt
+func newRequest(method, url string, headers []string, body []byte) (*http.Request, error) {
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = client.NewBytesSeekReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, url, reqBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid request")
+	}
+
+	for _, h := range headers {
+		key, value, _ := strings.Cut(h, ":")
+		req.Header.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	return req, nil
+}
+
+func printRequest(w io.Writer, req *http.Request) {
+	fmt.Fprintf(w, "> %s %s %s\n", req.Method, req.URL.RequestURI(), req.Proto)
+	fmt.Fprintf(w, "> Host: %s\n", req.URL.Host)
+	printHeader(w, "> ", req.Header)
+}
+
+func printAttempt(w io.Writer, attempt attemptResult) {
+	if attempt.Error != "" {
+		fmt.Fprintf(w, "* attempt %d failed in %.1fms: %s\n", attempt.Attempt, attempt.DurationMS, attempt.Error)
+		return
+	}
+
+	fmt.Fprintf(w, "* attempt %d: %d in %.1fms\n", attempt.Attempt, attempt.Status, attempt.DurationMS)
+}
+
+func printResponseHeader(w io.Writer, prefix string, resp *http.Response) {
+	fmt.Fprintf(w, "%s%s %s\n", prefix, resp.Proto, resp.Status)
+	printHeader(w, prefix, resp.Header)
+}
+
+// printHeader prints header sorted by key
+func printHeader(w io.Writer, prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, k, v)
+		}
+	}
+}
+
+// encodeBody keeps text bodies readable in the JSON output, other bodies are base64 encoded
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// credentialHeaders are always part of the key of coalesced requests, so that a response is never fanned
+// out to a caller presenting other credentials than the caller it was sent for
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// coalescer collapses identical concurrent GET and HEAD requests into one upstream request, requests
+// are identical when they share method, URL, credentials and the values of the selected headers
+type coalescer struct {
+	headers []string
+
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is an upstream request shared by several callers, the upstream request is canceled
+// once all of its callers gave up waiting
+type coalescedCall struct {
+	done    chan struct{}
+	resp    *http.Response
+	body    []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newCoalescer(headers []string) *coalescer {
+	return &coalescer{
+		headers: headers,
+		calls:   make(map[string]*coalescedCall),
+	}
+}
+
+// coalescible reports whether req may be coalesced, the response of a coalesced request is buffered
+// before being fanned out, which never completes for streams and serves no purpose for partial content
+func coalescible(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	if req.Header.Get("Range") != "" {
+		return false
+	}
+
+	for _, accept := range strings.Split(strings.Join(req.Header.Values("Accept"), ","), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == "text/event-stream" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// key identifies the requests coalesced with req, auth is the authenticator of the request options,
+// requests authorized by distinct authenticators are not coalesced
+func (co *coalescer) key(req *http.Request, auth authenticator) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	b.WriteString(req.URL.String())
+
+	if auth != nil {
+		fmt.Fprintf(&b, "\nauthenticator:%p", auth)
+	}
+
+	for _, h := range append(credentialHeaders, co.headers...) {
+		b.WriteByte('\n')
+		b.WriteString(h)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(req.Header.Values(h), ","))
+	}
+
+	return b.String()
+}
+
+// do sends req through send, unless an identical request is already in flight, in which case the caller
+// waits for its response. Every caller receives its own copy of the response with an independently
+// readable body.
+func (co *coalescer) do(req *http.Request, auth authenticator, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	key := co.key(req, auth)
+
+	co.mu.Lock()
+	call, ok := co.calls[key]
+	if !ok {
+		// the upstream request outlives the context of the caller starting it, it keeps the caller's
+		// span to be traced as its child
+		ctx, cancel := context.WithCancel(context.Background())
+		if sp := opentracing.SpanFromContext(req.Context()); sp != nil {
+			ctx = opentracing.ContextWithSpan(ctx, sp)
+		}
+
+		call = &coalescedCall{
+			done:   make(chan struct{}),
+			cancel: cancel,
+		}
+		co.calls[key] = call
+
+		go co.run(key, call, req.WithContext(ctx), send)
+	}
+	call.waiters++
+	co.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+
+		return call.response(req), nil
+	case <-req.Context().Done():
+		co.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if co.calls[key] == call {
+				delete(co.calls, key)
+			}
+		}
+		co.mu.Unlock()
+
+		return nil, req.Context().Err()
+	}
+}
+
+func (co *coalescer) run(key string, call *coalescedCall, req *http.Request, send func(*http.Request) (*http.Response, error)) {
+	defer call.cancel()
+
+	resp, err := send(req)
+	if err == nil {
+		// the body is read before the upstream context is canceled by the deferred call
+		call.body, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		call.resp = resp
+	}
+	call.err = err
+
+	co.mu.Lock()
+	if co.calls[key] == call {
+		delete(co.calls, key)
+	}
+	co.mu.Unlock()
+
+	close(call.done)
+}
+
+func (call *coalescedCall) response(req *http.Request) *http.Response {
+	resp := *call.resp
+	resp.Header = call.resp.Header.Clone()
+	resp.Body = io.NopCloser(bytes.NewReader(call.body))
+	resp.ContentLength = int64(len(call.body))
+	resp.Request = req
+
+	return &resp
+}
@@ -0,0 +1,259 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+func TestRequestCoalescing(t *testing.T) {
+	t.Run("Collapse identical concurrent requests", func(t *testing.T) {
+		var requests atomic.Int32
+		release := make(chan struct{})
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-release
+
+			_, err := w.Write([]byte("content " + r.Header.Get("Accept")))
+			require.NoError(t, err)
+		}))
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRequestCoalescing("Accept"))
+
+		const callers = 20
+
+		var wg sync.WaitGroup
+		bodies := make([]string, callers)
+
+		for i := 0; i < callers; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+				require.NoError(t, err)
+
+				// callers accepting different representations are not collapsed together
+				if i%2 == 0 {
+					req.Header.Set("Accept", "text/plain")
+				}
+
+				resp, err := testClient.Do(req)
+				require.NoError(t, err)
+
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				bodies[i] = string(body)
+			}(i)
+		}
+
+		// give the callers time to join the in-flight requests
+		time.Sleep(200 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(2), requests.Load())
+		for i, body := range bodies {
+			if i%2 == 0 {
+				assert.Equal(t, "content text/plain", body)
+			} else {
+				assert.Equal(t, "content ", body)
+			}
+		}
+	})
+
+	t.Run("Callers giving up don't affect the other callers", func(t *testing.T) {
+		var requests atomic.Int32
+		release := make(chan struct{})
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-release
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRequestCoalescing())
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		impatientDone := make(chan error)
+		go func() {
+			resp, err := testClient.Get(ctx, server.URL) //nolint: bodyclose
+			assert.Nil(t, resp)
+			impatientDone <- err
+		}()
+
+		patientDone := make(chan *http.Response)
+		go func() {
+			resp, err := testClient.Get(context.Background(), server.URL)
+			require.NoError(t, err)
+			patientDone <- resp
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+
+		assert.ErrorIs(t, <-impatientDone, context.Canceled)
+
+		close(release)
+		resp := <-patientDone
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("Cancel the upstream request once every caller gave up", func(t *testing.T) {
+		upstreamCanceled := make(chan struct{})
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			close(upstreamCanceled)
+		}))
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRequestCoalescing())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		resp, err := testClient.Get(ctx, server.URL) //nolint: bodyclose
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, resp)
+
+		select {
+		case <-upstreamCanceled:
+		case <-time.After(time.Second):
+			assert.Fail(t, "upstream request was not canceled")
+		}
+	})
+
+	t.Run("Keep callers presenting different credentials apart", func(t *testing.T) {
+		var requests atomic.Int32
+		release := make(chan struct{})
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-release
+
+			_, err := w.Write([]byte(r.Header.Get("Authorization") + r.Header.Get("Cookie")))
+			require.NoError(t, err)
+		}))
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRequestCoalescing())
+
+		credentials := []struct{ header, value string }{
+			{"Authorization", "Bearer a"},
+			{"Authorization", "Bearer b"},
+			{"Cookie", "session=c"},
+		}
+
+		var wg sync.WaitGroup
+		bodies := make([]string, len(credentials))
+
+		for i, c := range credentials {
+			wg.Add(1)
+
+			go func(i int, header, value string) {
+				defer wg.Done()
+
+				req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+				require.NoError(t, err)
+				req.Header.Set(header, value)
+
+				resp, err := testClient.Do(req)
+				require.NoError(t, err)
+
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				bodies[i] = string(body)
+			}(i, c.header, c.value)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(3), requests.Load())
+		assert.Equal(t, []string{"Bearer a", "Bearer b", "session=c"}, bodies)
+	})
+
+	t.Run("Don't coalesce event streams and range requests", func(t *testing.T) {
+		server, _ := newEventServer(t, "data: first\n\nhang")
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRequestCoalescing())
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		stream, err := testClient.Events(ctx, server.URL)
+		require.NoError(t, err)
+
+		defer stream.Close()
+
+		require.True(t, stream.Next())
+		assert.Equal(t, "first", stream.Event().Data)
+
+		var requests atomic.Int32
+		release := make(chan struct{})
+
+		rangeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-release
+
+			w.WriteHeader(http.StatusPartialContent)
+		}))
+
+		defer rangeServer.Close()
+
+		var wg sync.WaitGroup
+		for _, rangeSpec := range []string{"bytes=0-9", "bytes=10-19"} {
+			wg.Add(1)
+
+			go func(rangeSpec string) {
+				defer wg.Done()
+
+				req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, rangeServer.URL, nil)
+				require.NoError(t, err)
+				req.Header.Set("Range", rangeSpec)
+
+				resp, err := testClient.Do(req)
+				require.NoError(t, err)
+				resp.Body.Close()
+			}(rangeSpec)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(2), requests.Load())
+	})
+}
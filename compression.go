@@ -0,0 +1,196 @@
+package client
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Content codings built in the client
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// Encoder returns a writer compressing what is written to it into w, closing the writer flushes the
+// compressed stream but doesn't close w
+type Encoder func(w io.Writer) (io.WriteCloser, error)
+
+var encoders = struct {
+	sync.RWMutex
+	m map[string]Encoder
+}{
+	m: map[string]Encoder{
+		EncodingGzip: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		EncodingZstd: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+	},
+}
+
+// RegisterEncoder registers the encoder of a content coding to be used by WithRequestCompression, it
+// replaces any encoder previously registered for the coding
+func RegisterEncoder(encoding string, encoder Encoder) {
+	encoders.Lock()
+	defer encoders.Unlock()
+
+	encoders.m[encoding] = encoder
+}
+
+func lookupEncoder(encoding string) (Encoder, bool) {
+	encoders.RLock()
+	defer encoders.RUnlock()
+
+	encoder, ok := encoders.m[encoding]
+	return encoder, ok
+}
+
+type compressionOptions struct {
+	encoding string
+	minSize  int64
+}
+
+// encodedBody sends a compressed body, it falls back to the uncompressed body once the server refused
+// the content coding
+type encodedBody struct {
+	compressed requestBody
+	plain      requestBody
+	encoding   string
+	size       int64 // size of the compressed body, -1 when unknown
+
+	refused atomic.Bool
+}
+
+func (b *encodedBody) next() (io.ReadCloser, error) {
+	if b.refused.Load() {
+		return b.plain.next()
+	}
+
+	return b.compressed.next()
+}
+
+func (b *encodedBody) replayable() bool {
+	if b.refused.Load() {
+		return b.plain.replayable()
+	}
+
+	return b.compressed.replayable()
+}
+
+func (b *encodedBody) Close() error {
+	err := b.compressed.Close()
+	if pErr := b.plain.Close(); err == nil {
+		err = pErr
+	}
+
+	return err
+}
+
+// prepare sets the headers of req describing the body returned by next, the header of req must be
+// owned by req
+func (b *encodedBody) prepare(req *http.Request) {
+	if b.refused.Load() {
+		return
+	}
+
+	req.Header.Set("Content-Encoding", b.encoding)
+	req.ContentLength = b.size
+}
+
+// fallback switches to the uncompressed body once the server answered resp, it reports whether the
+// request should be sent again
+func (b *encodedBody) fallback(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusUnsupportedMediaType || b.refused.Load() || !b.plain.replayable() {
+		return false
+	}
+	b.refused.Store(true)
+
+	return true
+}
+
+// compressRequestBody compresses the body of req once, the compressed body is buffered as configured by
+// the options so that it is reused across retries. Bodies smaller than the minimum size of the options
+// are sent uncompressed.
+func compressRequestBody(req *http.Request, body requestBody, opts options) (requestBody, error) {
+	compression := opts.compressionOptions
+	if req.Header.Get("Content-Encoding") != "" ||
+		(req.ContentLength > 0 && req.ContentLength < compression.minSize) {
+		return body, nil
+	}
+
+	encoder, ok := lookupEncoder(compression.encoding)
+	if !ok {
+		return nil, errors.Errorf("unknown content encoding %q", compression.encoding)
+	}
+
+	plain, err := body.next()
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	plainSize := make(chan int64, 1)
+	go func() {
+		defer plain.Close()
+
+		var n int64
+		w, err := encoder(pw)
+		if err == nil {
+			n, err = io.Copy(w, plain)
+			if cErr := w.Close(); err == nil {
+				err = cErr
+			}
+		}
+		plainSize <- n
+		_ = pw.CloseWithError(err)
+	}()
+
+	if !body.replayable() {
+		// a body streamed once is compressed on the fly
+		return &encodedBody{
+			compressed: &oneShotBody{body: pr},
+			plain:      body,
+			encoding:   compression.encoding,
+			size:       -1,
+		}, nil
+	}
+
+	counter := &countingReader{r: pr}
+	compressed, err := bufferBody(io.NopCloser(counter), opts.bodyMemoryLimit, 0)
+	// closing the reader stops the compression if buffering failed
+	_ = pr.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "error compressing request body")
+	}
+
+	if <-plainSize < compression.minSize {
+		_ = compressed.Close()
+		return body, nil
+	}
+
+	return &encodedBody{
+		compressed: compressed,
+		plain:      body,
+		encoding:   compression.encoding,
+		size:       counter.n,
+	}, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
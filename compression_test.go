@@ -0,0 +1,181 @@
+package client_test
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// newDecompressingServer starts a server echoing the decoded request body along with its Content-Encoding
+func newDecompressingServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			gr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body = gr
+		case "zstd":
+			zr, err := zstd.NewReader(r.Body)
+			require.NoError(t, err)
+			defer zr.Close()
+			body = zr
+		case "deflate":
+			body = flate.NewReader(r.Body)
+		}
+
+		// the body is read entirely before writing the response
+		reqBody, err := io.ReadAll(body)
+		require.NoError(t, err)
+
+		w.Header().Set("X-Content-Encoding", r.Header.Get("Content-Encoding"))
+		_, err = w.Write(reqBody)
+		require.NoError(t, err)
+	}))
+}
+
+func TestRequestCompression(t *testing.T) {
+	body := strings.Repeat(`{"event":"page_view","user":"42"}`, 100)
+
+	post := func(t *testing.T, testClient *client.Client, url string, reqBody io.Reader) (string, string) {
+		resp, err := testClient.Post(context.Background(), url, reqBody)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp.Header.Get("X-Content-Encoding"), string(respBody)
+	}
+
+	server := newDecompressingServer(t)
+
+	defer server.Close()
+
+	t.Run("Compress bodies with the built in encoders", func(t *testing.T) {
+		for _, encoding := range []string{client.EncodingGzip, client.EncodingZstd} {
+			testClient := client.New(client.WithRequestCompression(encoding, 1024))
+
+			gotEncoding, gotBody := post(t, testClient, server.URL, strings.NewReader(body))
+			assert.Equal(t, encoding, gotEncoding)
+			assert.Equal(t, body, gotBody)
+
+			// bodies of unknown length are measured while being compressed
+			gotEncoding, gotBody = post(t, testClient, server.URL, io.MultiReader(strings.NewReader(body)))
+			assert.Equal(t, encoding, gotEncoding)
+			assert.Equal(t, body, gotBody)
+		}
+	})
+
+	t.Run("Send small bodies uncompressed", func(t *testing.T) {
+		testClient := client.New(client.WithRequestCompression(client.EncodingGzip, int64(len(body)+1)))
+
+		gotEncoding, gotBody := post(t, testClient, server.URL, strings.NewReader(body))
+		assert.Empty(t, gotEncoding)
+		assert.Equal(t, body, gotBody)
+
+		gotEncoding, gotBody = post(t, testClient, server.URL, io.MultiReader(strings.NewReader(body)))
+		assert.Empty(t, gotEncoding)
+		assert.Equal(t, body, gotBody)
+	})
+
+	t.Run("Compress bodies with registered encoders", func(t *testing.T) {
+		client.RegisterEncoder("deflate", func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.BestSpeed)
+		})
+
+		testClient := client.New(client.WithRequestCompression("deflate", 0))
+
+		gotEncoding, gotBody := post(t, testClient, server.URL, strings.NewReader(body))
+		assert.Equal(t, "deflate", gotEncoding)
+		assert.Equal(t, body, gotBody)
+	})
+
+	t.Run("Fail with unknown encodings", func(t *testing.T) {
+		testClient := client.New(client.WithRequestCompression("unknown", 0))
+
+		resp, err := testClient.Post(context.Background(), server.URL, strings.NewReader(body)) //nolint: bodyclose
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("Reuse the compressed body across retries", func(t *testing.T) {
+		var attempts atomic.Int32
+
+		flakyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+
+			gr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			reqBody, err := io.ReadAll(gr)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(reqBody))
+
+			if attempts.Add(1) == 1 {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				conn.Close()
+
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		defer flakyServer.Close()
+
+		testClient := client.New(
+			client.WithRetryPolicy(time.Second, 3),
+			client.WithRequestCompression(client.EncodingGzip, 0),
+			client.WithRequestBodySpooling(256, 0),
+		)
+
+		resp, err := testClient.Post(context.Background(), flakyServer.URL, io.MultiReader(strings.NewReader(body)))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("Fall back to uncompressed bodies on 415", func(t *testing.T) {
+		var requests atomic.Int32
+
+		strictServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+
+			if r.Header.Get("Content-Encoding") != "" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+
+			reqBody, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			_, err = w.Write(reqBody)
+			require.NoError(t, err)
+		}))
+
+		defer strictServer.Close()
+
+		testClient := client.New(client.WithRequestCompression(client.EncodingZstd, 0))
+
+		gotEncoding, gotBody := post(t, testClient, strictServer.URL, strings.NewReader(body))
+		assert.Empty(t, gotEncoding)
+		assert.Equal(t, body, gotBody)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+}
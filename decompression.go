@@ -0,0 +1,220 @@
+package client
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Content codings decoded by the client besides the ones it can encode
+const (
+	EncodingBrotli  = "br"
+	EncodingDeflate = "deflate"
+)
+
+// zstdMaxWindow is the largest window a zstd content coding may use, as defined by RFC 9659
+const zstdMaxWindow = 8 << 20
+
+// ErrDecompressedBodyTooLarge is returned when reading a decompressed response body beyond the size limit
+// given to WithResponseDecompression
+var ErrDecompressedBodyTooLarge = errors.New("decompressed response body exceeds the size limit")
+
+// Decoder returns a reader decompressing r, closing the reader releases its resources but doesn't close r
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+var decoders = struct {
+	sync.RWMutex
+	m     map[string]Decoder
+	order []string // order of preference advertised by Accept-Encoding
+}{
+	m: map[string]Decoder{
+		EncodingBrotli: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(brotli.NewReader(r)), nil
+		},
+		EncodingZstd: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+			if err != nil {
+				return nil, err
+			}
+
+			return zr.IOReadCloser(), nil
+		},
+		EncodingGzip: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		EncodingDeflate: newDeflateReader,
+	},
+	order: []string{EncodingBrotli, EncodingZstd, EncodingGzip, EncodingDeflate},
+}
+
+// RegisterDecoder registers the decoder of a content coding to be advertised and decoded by the client
+// when WithResponseDecompression is set, it replaces any decoder previously registered for the coding
+func RegisterDecoder(encoding string, decoder Decoder) {
+	decoders.Lock()
+	defer decoders.Unlock()
+
+	if _, ok := decoders.m[encoding]; !ok {
+		decoders.order = append(decoders.order, encoding)
+	}
+	decoders.m[encoding] = decoder
+}
+
+func lookupDecoder(encoding string) (Decoder, bool) {
+	decoders.RLock()
+	defer decoders.RUnlock()
+
+	decoder, ok := decoders.m[encoding]
+	return decoder, ok
+}
+
+func acceptEncoding() string {
+	decoders.RLock()
+	defer decoders.RUnlock()
+
+	return strings.Join(decoders.order, ", ")
+}
+
+// newDeflateReader decodes the deflate content coding, which is a zlib stream, but many servers send a
+// raw deflate stream instead
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	// a zlib stream starts with a deflate compression method and a header checksum
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+type decompressionOptions struct {
+	maxSize int64
+}
+
+// prepareDecompression advertises the decoded content codings on req, unless the caller did already. The
+// returned request shares everything with req except its header.
+func prepareDecompression(req *http.Request) *http.Request {
+	// content codings apply to the whole representation, they would make byte ranges meaningless
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return req
+	}
+
+	dReq := req.WithContext(req.Context())
+	dReq.Header = req.Header.Clone()
+	dReq.Header.Set("Accept-Encoding", acceptEncoding())
+
+	return dReq
+}
+
+// decompressResponse replaces the body of resp by its decoded content when all of its content codings
+// are registered, the headers describing the encoded body are removed
+func decompressResponse(resp *http.Response, opts *decompressionOptions) {
+	contentEncoding := resp.Header.Get("Content-Encoding")
+	if contentEncoding == "" || resp.StatusCode == http.StatusPartialContent ||
+		resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified ||
+		(resp.Request != nil && resp.Request.Method == http.MethodHead) {
+		return
+	}
+
+	codings := strings.Split(contentEncoding, ",")
+	chain := make([]Decoder, 0, len(codings))
+	// codings are listed in the order they were applied, they are decoded the other way round
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "identity" {
+			continue
+		}
+
+		decoder, ok := lookupDecoder(coding)
+		if !ok {
+			return
+		}
+		chain = append(chain, decoder)
+	}
+
+	resp.Body = &decodingReadCloser{
+		body:    resp.Body,
+		chain:   chain,
+		maxSize: opts.maxSize,
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// decodingReadCloser decodes body through chain, the decoders are created by the first Read so that
+// empty bodies are never decoded
+type decodingReadCloser struct {
+	body    io.ReadCloser
+	chain   []Decoder
+	maxSize int64
+
+	decoded  io.Reader
+	decoders []io.Closer
+	read     int64
+	err      error
+}
+
+func (rc *decodingReadCloser) Read(p []byte) (int, error) {
+	if rc.err != nil {
+		return 0, rc.err
+	}
+
+	if rc.decoded == nil {
+		var r io.Reader = rc.body
+		for _, decoder := range rc.chain {
+			dr, err := decoder(r)
+			if err == io.EOF {
+				// the body is empty
+				rc.err = err
+				return 0, rc.err
+			}
+			if err != nil {
+				rc.err = errors.Wrap(err, "error decoding response body")
+				return 0, rc.err
+			}
+			rc.decoders = append(rc.decoders, dr)
+			r = dr
+		}
+		rc.decoded = r
+	}
+
+	if rc.maxSize > 0 && int64(len(p)) > rc.maxSize-rc.read+1 {
+		// read one byte past the limit to tell a body of exactly maxSize bytes from a larger one
+		p = p[:rc.maxSize-rc.read+1]
+	}
+
+	n, err := rc.decoded.Read(p)
+	rc.read += int64(n)
+	if rc.maxSize > 0 && rc.read > rc.maxSize {
+		rc.err = ErrDecompressedBodyTooLarge
+		return n - int(rc.read-rc.maxSize), rc.err
+	}
+	if err != nil && err != io.EOF {
+		err = errors.Wrap(err, "error decoding response body")
+	}
+
+	return n, err
+}
+
+func (rc *decodingReadCloser) Close() error {
+	for _, d := range rc.decoders {
+		_ = d.Close()
+	}
+
+	return rc.body.Close()
+}
@@ -0,0 +1,146 @@
+package client_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+func encode(t *testing.T, encoding string, content []byte) []byte {
+	var buf bytes.Buffer
+
+	var w io.WriteCloser
+	switch encoding {
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		w = zw
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		require.NoError(t, err)
+		w = fw
+	}
+
+	_, err := w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestResponseDecompression(t *testing.T) {
+	content := strings.Repeat("compressible content ", 1000)
+
+	// the server encodes its response with the coding given by the query, the Accept-Encoding of the
+	// request is echoed in X-Accept-Encoding
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+
+		encoding := r.URL.Query().Get("encoding")
+		if encoding == "" {
+			_, err := w.Write([]byte(content))
+			require.NoError(t, err)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", strings.TrimPrefix(encoding, "raw-"))
+		_, err := w.Write(encode(t, encoding, []byte(content)))
+		require.NoError(t, err)
+	}))
+
+	defer server.Close()
+
+	t.Run("Advertise and decode the built in codings", func(t *testing.T) {
+		testClient := client.New(client.WithResponseDecompression(0))
+
+		for _, encoding := range []string{"br", "zstd", "gzip", "deflate", "raw-deflate", ""} {
+			resp, err := testClient.Get(context.Background(), server.URL+"?encoding="+encoding)
+			require.NoError(t, err)
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			assert.Equal(t, "br, zstd, gzip, deflate", resp.Header.Get("X-Accept-Encoding"))
+			assert.Empty(t, resp.Header.Get("Content-Encoding"))
+			assert.Equal(t, content, string(body), encoding)
+		}
+	})
+
+	t.Run("Keep the Accept-Encoding of the request", func(t *testing.T) {
+		testClient := client.New(client.WithResponseDecompression(0))
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"?encoding=gzip", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+
+		resp, err := testClient.Do(req)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, "gzip", resp.Header.Get("X-Accept-Encoding"))
+		assert.Equal(t, content, string(body))
+	})
+
+	t.Run("Limit the size of decoded bodies", func(t *testing.T) {
+		testClient := client.New(client.WithResponseDecompression(int64(len(content) - 1)))
+
+		resp, err := testClient.Get(context.Background(), server.URL+"?encoding=zstd")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, client.ErrDecompressedBodyTooLarge)
+		assert.Len(t, body, len(content)-1)
+
+		// a body of exactly the limit is read entirely
+		testClient = client.New(client.WithResponseDecompression(int64(len(content))))
+
+		resp, err = testClient.Get(context.Background(), server.URL+"?encoding=br")
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		body, err = io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(body))
+	})
+
+	t.Run("Don't advertise codings on range requests", func(t *testing.T) {
+		testClient := client.New(client.WithResponseDecompression(0))
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", "bytes=0-9")
+
+		resp, err := testClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.NotContains(t, resp.Header.Get("X-Accept-Encoding"), "br")
+	})
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"net"
+)
+
+// DialContextFunc dials the connections of a Client, see http.Transport.DialContext
+type DialContextFunc = func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// UnixSocketDialer returns a DialContextFunc connecting to the Unix domain socket at path regardless
+// of the address being dialed
+func UnixSocketDialer(path string) DialContextFunc {
+	var d net.Dialer
+
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// newDialContext returns the http.Transport.DialContext function selecting the dialer of an address,
+// a dialer registered for the exact "host:port" address wins over the one registered for the host,
+// addresses without registered dialer are dialed with defaultDial, or base if defaultDial is nil
+func newDialContext(base, defaultDial DialContextFunc, hostDials map[string]DialContextFunc) DialContextFunc {
+	if defaultDial != nil {
+		base = defaultDial
+	}
+
+	if len(hostDials) == 0 {
+		return base
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if dial, ok := hostDials[addr]; ok {
+			return dial(ctx, network, addr)
+		}
+
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			if dial, ok := hostDials[host]; ok {
+				return dial(ctx, network, addr)
+			}
+		}
+
+		return base(ctx, network, addr)
+	}
+}
@@ -0,0 +1,118 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+func newUnixSocketServer(t *testing.T, handler http.Handler) (*httptest.Server, string) {
+	path := filepath.Join(t.TempDir(), "server.sock")
+
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = l
+	server.Start()
+
+	return server, path
+}
+
+func TestUnixSocket(t *testing.T) {
+	const body = `test body`
+
+	server, path := newUnixSocketServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		w.WriteHeader(http.StatusOK)
+		_, err = w.Write([]byte(r.Host + r.URL.Path + string(reqBody)))
+		require.NoError(t, err)
+	}))
+
+	defer server.Close()
+
+	t.Run("Send requests to a Unix socket with http URLs", func(t *testing.T) {
+		opentracing.SetGlobalTracer(mocktracer.New())
+
+		testClient := client.New(client.WithUnixSocket(path))
+
+		resp, err := testClient.Post(context.Background(), "http://docker/containers/json", strings.NewReader(body),
+			client.WithTracingOptions(true, "testOp"),
+			client.WithSpanCarrierInjected(),
+		)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "docker/containers/json"+body, string(respBody))
+	})
+
+	t.Run("Send requests of a specific host to a Unix socket", func(t *testing.T) {
+		tcpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		defer tcpServer.Close()
+
+		testClient := client.New(client.WithHostUnixSocket("agent", path))
+
+		resp, err := testClient.Get(context.Background(), "http://agent/status")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = testClient.Get(context.Background(), tcpServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+}
+
+func TestDialContext(t *testing.T) {
+	t.Run("Dial connections with the custom dialer", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		defer server.Close()
+
+		var (
+			dialCount atomic.Int32
+			dialer    net.Dialer
+		)
+
+		testClient := client.New(client.WithHostDialContext("backend:80", func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialCount.Add(1)
+			assert.Equal(t, "backend:80", addr)
+
+			return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+		}))
+
+		resp, err := testClient.Get(context.Background(), "http://backend/")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(1), dialCount.Load())
+	})
+}
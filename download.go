@@ -0,0 +1,427 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kamilsk/retry/v5"
+	"github.com/kamilsk/retry/v5/strategy"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrResourceChanged is returned when the resource being downloaded changed between two requests
+	ErrResourceChanged = errors.New("resource changed during download")
+	// ErrChecksumMismatch is returned when the downloaded content doesn't match the expected checksum
+	ErrChecksumMismatch = errors.New("downloaded content doesn't match the checksum")
+)
+
+const downloadBufferSize = 32 << 10
+
+type downloadOptions struct {
+	chunks       int
+	minChunkSize int64
+	progress     func(written, total int64)
+	newHash      func() hash.Hash
+	checksum     []byte
+}
+
+// download returns the download options for modification
+func (o *options) download() *downloadOptions {
+	return copyOnWrite(&o.downloadOptions)
+}
+
+// Download writes the resource at url to dst and returns its size. Each request of the download is a single
+// attempt, the retry policy applies to the download as a whole: an interrupted transfer is resumed where it
+// stopped with a Range request, made conditional by If-Range on the validator of the first response, so that
+// the transfer fails with ErrResourceChanged rather than mixing two versions of the resource. Downloads
+// bypass the cache of the client and ask for the resource without content coding.
+//
+// WithDownloadChunks splits the download into ranges fetched in parallel, WithDownloadProgress reports its
+// progress and WithDownloadChecksum verifies the downloaded content, which requires dst to implement
+// io.ReaderAt.
+func (c *Client) Download(ctx context.Context, url string, dst io.WriterAt, opts ...Option) (int64, error) {
+	requestOpts := c.options
+	for _, o := range opts {
+		o.apply(&requestOpts, c.generator)
+	}
+
+	dlOpts := &downloadOptions{}
+	if requestOpts.downloadOptions != nil {
+		dlOpts = requestOpts.downloadOptions
+	}
+
+	d := &download{
+		client:      c,
+		url:         url,
+		dst:         dst,
+		opts:        dlOpts,
+		requestOpts: append(opts[:len(opts):len(opts)], withSingleAttempt(), withoutCache()),
+		strategies:  requestOpts.retryPolicy.strategies(clockOf(requestOpts)),
+		total:       -1,
+	}
+
+	var err error
+	if dlOpts.chunks > 1 && d.probe(ctx) {
+		err = d.fetchChunks(ctx)
+	} else {
+		err = d.fetchRange(ctx, 0, -1)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	written := d.written.Load()
+	if dlOpts.newHash != nil {
+		if err := d.verify(written); err != nil {
+			return 0, err
+		}
+	}
+
+	return written, nil
+}
+
+// DownloadFile downloads the resource at url to the file at path, the file is written under a temporary
+// name and renamed once the download succeeded
+func (c *Client) DownloadFile(ctx context.Context, url, path string, opts ...Option) (int64, error) {
+	tmpPath := path + ".part"
+
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return 0, errors.Wrap(err, "error creating download file")
+	}
+
+	n, err := c.Download(ctx, url, f, opts...)
+	if cErr := f.Close(); err == nil && cErr != nil {
+		err = errors.Wrap(cErr, "error closing download file")
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// withoutCache sends requests to the server whatever the cache of the client, the ranges of a download
+// would be served the cached response of the whole resource otherwise
+func withoutCache() Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.cacheOptions = nil
+	})
+}
+
+// withSingleAttempt disables the retries of a request while keeping its timeout
+func withSingleAttempt() Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.retryPolicy = &retryPolicy{
+			requestTimeout:  o.retryPolicy.requestTimeout,
+			retryStrategies: []strategy.Strategy{strategy.Limit(1)},
+		}
+	})
+}
+
+type download struct {
+	client      *Client
+	url         string
+	dst         io.WriterAt
+	opts        *downloadOptions
+	requestOpts []Option
+	strategies  []strategy.Strategy
+
+	// validator and total are learnt from the first response, before ranges are fetched concurrently
+	validator string
+	total     int64
+
+	written    atomic.Int64
+	progressMu sync.Mutex
+}
+
+// probe learns the size and validator of the resource with a HEAD request, it reports whether the
+// resource can be downloaded in ranges
+func (d *download) probe(ctx context.Context) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, d.url, nil)
+	if err != nil {
+		return false
+	}
+	// the size of the resource is the one of its identity representation, which ranges apply to
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := d.client.Do(req, d.requestOpts...)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.ContentLength <= 0 ||
+		!strings.Contains(resp.Header.Get("Accept-Ranges"), "bytes") {
+		return false
+	}
+
+	d.validator = validatorOf(resp)
+	d.total = resp.ContentLength
+
+	return d.validator != ""
+}
+
+// fetchChunks downloads the resource in ranges fetched in parallel, the first failing range cancels the
+// others
+func (d *download) fetchChunks(ctx context.Context) error {
+	chunkSize := (d.total + int64(d.opts.chunks) - 1) / int64(d.opts.chunks)
+	if chunkSize < d.opts.minChunkSize {
+		chunkSize = d.opts.minChunkSize
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for start := int64(0); start < d.total; start += chunkSize {
+		end := start + chunkSize - 1
+		if end >= d.total {
+			end = d.total - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+
+			if err := d.fetchRange(ctx, start, end); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+// fetchRange downloads the bytes from start to end of the resource, or up to its end when end is negative,
+// interrupted transfers are resumed where they stopped
+func (d *download) fetchRange(ctx context.Context, start, end int64) error {
+	offset := start
+	action := func(ctx context.Context) error {
+		n, err := d.fetch(ctx, offset, end)
+		offset += n
+
+		return err
+	}
+
+	return unwrapPermanent(retry.Do(ctx, action, d.strategies...))
+}
+
+// fetch sends one request for the bytes from offset to end and writes them to dst, it returns the number of
+// bytes written even if the transfer is interrupted
+func (d *download) fetch(ctx context.Context, offset, end int64) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return 0, &permanentError{errors.Wrap(err, "error creating request")}
+	}
+
+	// a Range request is sent from the start as well, so that the response is never transparently
+	// decompressed and offsets always apply to the transferred bytes
+	rangeSpec := fmt.Sprintf("bytes=%d-", offset)
+	if end >= 0 {
+		rangeSpec += strconv.FormatInt(end, 10)
+	}
+	req.Header.Set("Range", rangeSpec)
+	req.Header.Set("Accept-Encoding", "identity")
+	if d.validator != "" {
+		req.Header.Set("If-Range", d.validator)
+	}
+
+	resp, err := d.client.Do(req, d.requestOpts...)
+	if err != nil {
+		return 0, markPermanent(err)
+	}
+	defer resp.Body.Close()
+
+	body := io.Reader(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		rangeStart, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || rangeStart != offset {
+			return 0, &permanentError{errors.Errorf("unexpected Content-Range %q", resp.Header.Get("Content-Range"))}
+		}
+		if err := d.checkValidator(resp); err != nil {
+			return 0, err
+		}
+		if d.total < 0 {
+			d.total = total
+		}
+	case resp.StatusCode == http.StatusOK:
+		// the server ignored the Range header, If-Range failing means the resource changed
+		if d.validator != "" && end >= 0 || d.validator != "" && validatorOf(resp) != d.validator {
+			return 0, &permanentError{ErrResourceChanged}
+		}
+		if err := d.checkValidator(resp); err != nil {
+			return 0, err
+		}
+		if d.total < 0 && resp.ContentLength >= 0 {
+			d.total = resp.ContentLength
+		}
+
+		// the bytes already written are skipped
+		if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+			return 0, err
+		}
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		return 0, errors.Errorf("unexpected status %q", resp.Status)
+	default:
+		return 0, &permanentError{errors.Errorf("unexpected status %q", resp.Status)}
+	}
+
+	if end >= 0 {
+		body = io.LimitReader(body, end-offset+1)
+	}
+
+	n, err := d.copy(body, offset)
+	if err != nil {
+		return n, err
+	}
+
+	expected := end
+	if expected < 0 {
+		expected = d.total - 1
+	}
+	if expected >= 0 && offset+n != expected+1 {
+		return n, io.ErrUnexpectedEOF
+	}
+
+	return n, nil
+}
+
+// checkValidator records the validator of the first response and checks that the following responses
+// carry the same one
+func (d *download) checkValidator(resp *http.Response) error {
+	validator := validatorOf(resp)
+	if d.validator == "" {
+		d.validator = validator
+		return nil
+	}
+
+	if validator != "" && validator != d.validator {
+		return &permanentError{ErrResourceChanged}
+	}
+
+	return nil
+}
+
+// copy writes body to dst from offset, reporting the progress of the download
+func (d *download) copy(body io.Reader, offset int64) (int64, error) {
+	buf := make([]byte, downloadBufferSize)
+
+	var written int64
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, wErr := d.dst.WriteAt(buf[:n], offset+written); wErr != nil {
+				return written, &permanentError{errors.Wrap(wErr, "error writing download")}
+			}
+			written += int64(n)
+			d.reportProgress(int64(n))
+		}
+
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func (d *download) reportProgress(n int64) {
+	written := d.written.Add(n)
+	if d.opts.progress == nil {
+		return
+	}
+
+	// progress is reported in order by the parallel ranges
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+
+	d.opts.progress(written, d.total)
+}
+
+// verify compares the checksum of the downloaded content with the expected one
+func (d *download) verify(size int64) error {
+	ra, ok := d.dst.(io.ReaderAt)
+	if !ok {
+		return errors.New("checksum verification requires the destination to implement io.ReaderAt")
+	}
+
+	h := d.opts.newHash()
+	if _, err := io.Copy(h, io.NewSectionReader(ra, 0, size)); err != nil {
+		return errors.Wrap(err, "error reading download")
+	}
+
+	if !bytes.Equal(h.Sum(nil), d.opts.checksum) {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+// validatorOf returns the strong ETag of resp, or its Last-Modified date when it has none, as the value
+// of an If-Range header
+func validatorOf(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return resp.Header.Get("Last-Modified")
+}
+
+// parseContentRange parses a Content-Range header of the form "bytes start-end/total", total is -1 when
+// unknown
+func parseContentRange(s string) (start, total int64, ok bool) {
+	rest, found := strings.CutPrefix(s, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+
+	byteRange, size, found := strings.Cut(rest, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	first, _, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+
+	return start, total, true
+}
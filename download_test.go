@@ -0,0 +1,226 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// writerAt is an in-memory io.WriterAt and io.ReaderAt
+type writerAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+
+	return copy(w.buf[off:], p), nil
+}
+
+func (w *writerAt) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(w.buf).ReadAt(p, off)
+}
+
+// newArtifactServer serves content with the given ETag, the first interrupted requests are cut halfway
+func newArtifactServer(t *testing.T, content []byte, etag *atomic.Value, interrupted int32) (*httptest.Server, *[]string) {
+	var (
+		mu       sync.Mutex
+		requests []string
+		served   atomic.Int32
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		mu.Unlock()
+
+		assert.Equal(t, "identity", r.Header.Get("Accept-Encoding"))
+		w.Header().Set("ETag", etag.Load().(string))
+		w.Header().Set("Cache-Control", "max-age=60")
+
+		if r.Method == http.MethodGet && served.Add(1) <= interrupted {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			_, err := w.Write(content[:len(content)/2])
+			require.NoError(t, err)
+			w.(http.Flusher).Flush()
+
+			panic(http.ErrAbortHandler)
+		}
+
+		http.ServeContent(w, r, "artifact", time.Time{}, bytes.NewReader(content))
+	}))
+
+	return server, &requests
+}
+
+func TestDownload(t *testing.T) {
+	content := []byte(strings.Repeat("artifact content ", 10000))
+
+	t.Run("Resume interrupted transfers", func(t *testing.T) {
+		var etag atomic.Value
+		etag.Store(`"v1"`)
+
+		server, requests := newArtifactServer(t, content, &etag, 1)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		dst := &writerAt{}
+		n, err := testClient.Download(context.Background(), server.URL, dst)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(len(content)), n)
+		assert.Equal(t, content, dst.buf)
+		require.Len(t, *requests, 2)
+		assert.Equal(t, "GET bytes=0- ", (*requests)[0])
+		assert.Equal(t, "GET bytes="+strconv.Itoa(len(content)/2)+`- "v1"`, (*requests)[1])
+	})
+
+	t.Run("Fail when the resource changes", func(t *testing.T) {
+		var etag atomic.Value
+		etag.Store(`"v1"`)
+
+		server, _ := newArtifactServer(t, content, &etag, 1)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		dst := &writerAt{}
+		n, err := testClient.Download(context.Background(), server.URL, dst, client.WithDownloadProgress(func(written, total int64) {
+			// the resource changes once the first request was interrupted
+			if written == int64(len(content)/2) {
+				etag.Store(`"v2"`)
+			}
+		}))
+		assert.ErrorIs(t, err, client.ErrResourceChanged)
+		assert.Zero(t, n)
+	})
+
+	t.Run("Download ranges in parallel", func(t *testing.T) {
+		var etag atomic.Value
+		etag.Store(`"v1"`)
+
+		server, requests := newArtifactServer(t, content, &etag, 0)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+		sum := sha256.Sum256(content)
+		path := filepath.Join(t.TempDir(), "artifact")
+
+		var lastWritten, lastTotal int64
+		n, err := testClient.DownloadFile(context.Background(), server.URL, path,
+			client.WithDownloadChunks(4, 1024),
+			client.WithDownloadChecksum(sha256.New, sum[:]),
+			client.WithDownloadProgress(func(written, total int64) {
+				lastWritten, lastTotal = written, total
+			}),
+		)
+		require.NoError(t, err)
+
+		downloaded, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(len(content)), n)
+		assert.Equal(t, content, downloaded)
+		assert.Equal(t, int64(len(content)), lastWritten)
+		assert.Equal(t, int64(len(content)), lastTotal)
+		// a HEAD request probes the resource, then each range is fetched
+		assert.Len(t, *requests, 5)
+	})
+
+	t.Run("Bypass the cache", func(t *testing.T) {
+		var etag atomic.Value
+		etag.Store(`"v1"`)
+
+		server, requests := newArtifactServer(t, content, &etag, 0)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithCache(client.NewMemoryCache(1 << 20)))
+
+		// the whole resource is cached
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "identity")
+
+		resp, err := testClient.Do(req)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		path := filepath.Join(t.TempDir(), "artifact")
+		n, err := testClient.DownloadFile(context.Background(), server.URL, path, client.WithDownloadChunks(4, 1))
+		require.NoError(t, err)
+
+		downloaded, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(len(content)), n)
+		assert.Equal(t, content, downloaded)
+		assert.Len(t, *requests, 6)
+	})
+
+	t.Run("Download identity representations with decompression", func(t *testing.T) {
+		var etag atomic.Value
+		etag.Store(`"v1"`)
+
+		server, requests := newArtifactServer(t, content, &etag, 0)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithResponseDecompression(0))
+
+		dst := &writerAt{}
+		n, err := testClient.Download(context.Background(), server.URL, dst, client.WithDownloadChunks(4, 1024))
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(len(content)), n)
+		assert.Equal(t, content, dst.buf)
+		assert.Len(t, *requests, 5)
+	})
+
+	t.Run("Verify checksums", func(t *testing.T) {
+		var etag atomic.Value
+		etag.Store(`"v1"`)
+
+		server, _ := newArtifactServer(t, content, &etag, 0)
+
+		defer server.Close()
+
+		testClient := client.New()
+		sum := sha256.Sum256([]byte("other content"))
+		path := filepath.Join(t.TempDir(), "artifact")
+
+		_, err := testClient.DownloadFile(context.Background(), server.URL, path, client.WithDownloadChecksum(sha256.New, sum[:]))
+		assert.ErrorIs(t, err, client.ErrChecksumMismatch)
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FormFile is a file part of a multipart/form-data body. The content of the file is either read from
+// Content, which holds Size bytes, or from the file named by Path, which is opened again by each attempt.
+type FormFile struct {
+	FieldName   string
+	FileName    string // defaults to the base name of Path
+	ContentType string // defaults to application/octet-stream
+
+	Content io.ReaderAt
+	Size    int64
+
+	Path string
+}
+
+// PostForm sends values as an application/x-www-form-urlencoded body
+func (c *Client) PostForm(ctx context.Context, url string, values url.Values, opts ...Option) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.Do(req, opts...)
+}
+
+// PostMultipart sends fields and files as a multipart/form-data body. The body is streamed, files are never
+// buffered, and generated again for each attempt, its Content-Length is computed from the sizes of the files.
+func (c *Client) PostMultipart(ctx context.Context, url string, fields url.Values, files []FormFile, opts ...Option) (*http.Response, error) {
+	body, err := newMultipartBody(fields, files)
+	if err != nil {
+		return nil, errors.Wrap(err, "error preparing multipart body")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	req.Header.Set("Content-Type", body.contentType)
+	req.ContentLength = body.size
+	req.GetBody = body.open
+	if req.Body, err = body.open(); err != nil {
+		return nil, errors.Wrap(err, "error opening multipart body")
+	}
+
+	return c.Do(req, opts...)
+}
+
+// multipartBody renders a multipart body once, as a sequence of the rendered boundaries, headers and
+// fields between the file contents, so that it can be streamed as many times as needed
+type multipartBody struct {
+	contentType string
+	size        int64
+	segments    []multipartSegment
+}
+
+// multipartSegment is either rendered bytes or the content of a file
+type multipartSegment struct {
+	rendered []byte
+	file     *FormFile
+}
+
+func newMultipartBody(fields url.Values, files []FormFile) (*multipartBody, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	// fields are sorted, as done by url.Values.Encode, so that bodies are reproducible
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range fields[k] {
+			if err := mw.WriteField(k, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	body := &multipartBody{contentType: mw.FormDataContentType()}
+
+	for i := range files {
+		file := files[i]
+		if file.Path != "" {
+			info, err := os.Stat(file.Path)
+			if err != nil {
+				return nil, err
+			}
+			file.Size = info.Size()
+
+			if file.FileName == "" {
+				file.FileName = filepath.Base(file.Path)
+			}
+		} else if file.Content == nil {
+			return nil, errors.Errorf("file part %q has no content", file.FieldName)
+		}
+
+		if file.ContentType == "" {
+			file.ContentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", "form-data; name="+quote(file.FieldName)+"; filename="+quote(file.FileName))
+		header.Set("Content-Type", file.ContentType)
+
+		// the part header is rendered in buf, the content of the file is streamed after it
+		if _, err := mw.CreatePart(header); err != nil {
+			return nil, err
+		}
+		body.appendRendered(&buf)
+		body.segments = append(body.segments, multipartSegment{file: &file})
+		body.size += file.Size
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	body.appendRendered(&buf)
+
+	return body, nil
+}
+
+func (b *multipartBody) appendRendered(buf *bytes.Buffer) {
+	rendered := bytes.Clone(buf.Bytes())
+	buf.Reset()
+
+	b.segments = append(b.segments, multipartSegment{rendered: rendered})
+	b.size += int64(len(rendered))
+}
+
+// open returns a new reader of the body, it implements http.Request.GetBody
+func (b *multipartBody) open() (io.ReadCloser, error) {
+	readers := make([]io.Reader, 0, len(b.segments))
+	var closers []io.Closer
+
+	for _, s := range b.segments {
+		switch {
+		case s.file == nil:
+			readers = append(readers, bytes.NewReader(s.rendered))
+		case s.file.Path != "":
+			f, err := os.Open(s.file.Path)
+			if err != nil {
+				for _, c := range closers {
+					_ = c.Close()
+				}
+				return nil, err
+			}
+			closers = append(closers, f)
+			// the file is read up to the size the Content-Length was computed from
+			readers = append(readers, io.LimitReader(f, s.file.Size))
+		default:
+			readers = append(readers, io.NewSectionReader(s.file.Content, 0, s.file.Size))
+		}
+	}
+
+	return &multiReadCloser{
+		Reader:  io.MultiReader(readers...),
+		closers: closers,
+	}, nil
+}
@@ -0,0 +1,122 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+func TestPostForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		require.NoError(t, r.ParseForm())
+
+		_, err := w.Write([]byte(r.PostForm.Encode()))
+		require.NoError(t, err)
+	}))
+
+	defer server.Close()
+
+	testClient := client.New()
+
+	values := url.Values{"name": {"gopher"}, "tags": {"a", "b&c"}}
+
+	resp, err := testClient.PostForm(context.Background(), server.URL, values)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, values.Encode(), string(body))
+}
+
+func TestPostMultipart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("a,b,c\n", 1000)), 0o600))
+
+	fields := url.Values{"name": {"gopher"}}
+	files := []client.FormFile{
+		{
+			FieldName:   "avatar",
+			FileName:    "avatar.png",
+			ContentType: "image/png",
+			Content:     strings.NewReader("png content"),
+			Size:        int64(len("png content")),
+		},
+		{
+			FieldName: "report",
+			Path:      path,
+		},
+	}
+
+	var attempts atomic.Int32
+
+	// the server checks the body of each attempt and drops the connection of the first one
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEqual(t, int64(-1), r.ContentLength)
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+
+		assert.Equal(t, "gopher", r.FormValue("name"))
+
+		avatar, header, err := r.FormFile("avatar")
+		require.NoError(t, err)
+		content, err := io.ReadAll(avatar)
+		require.NoError(t, err)
+		assert.Equal(t, "png content", string(content))
+		assert.Equal(t, "avatar.png", header.Filename)
+		assert.Equal(t, "image/png", header.Header.Get("Content-Type"))
+
+		report, header, err := r.FormFile("report")
+		require.NoError(t, err)
+		content, err = io.ReadAll(report)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat("a,b,c\n", 1000), string(content))
+		assert.Equal(t, "report.csv", header.Filename)
+		assert.Equal(t, "application/octet-stream", header.Header.Get("Content-Type"))
+
+		if attempts.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer server.Close()
+
+	testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+	t.Run("Regenerate the body for each attempt", func(t *testing.T) {
+		resp, err := testClient.PostMultipart(context.Background(), server.URL, fields, files)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("Fail with missing files", func(t *testing.T) {
+		missing := []client.FormFile{{FieldName: "report", Path: filepath.Join(t.TempDir(), "missing.csv")}}
+
+		resp, err := testClient.PostMultipart(context.Background(), server.URL, fields, missing) //nolint: bodyclose
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.Nil(t, resp)
+	})
+}
@@ -1,9 +1,12 @@
 module github.com/zackwwu/http-client-go
 
-go 1.20
+go 1.22
 
 require (
+	github.com/HdrHistogram/hdrhistogram-go v1.1.2
+	github.com/andybalholm/brotli v1.2.0
 	github.com/kamilsk/retry/v5 v5.0.0-rc8
+	github.com/klauspost/compress v1.18.0
 	github.com/opentracing/opentracing-go v1.2.0
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.7.1
@@ -0,0 +1,228 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxJSONLineSize is the longest line StreamJSONLines accepts when given no limit
+const DefaultMaxJSONLineSize = 1 << 20
+
+// ErrJSONLineTooLong is returned, wrapped in a JSONLineError, when a line exceeds the size limit
+var ErrJSONLineTooLong = errors.New("JSON line exceeds the size limit")
+
+// JSONLineError locates an error in a JSON lines stream
+type JSONLineError struct {
+	Line   int   // number of the line, starting from 1
+	Offset int64 // offset of the line in the stream
+	Err    error
+}
+
+func (e *JSONLineError) Error() string {
+	return fmt.Sprintf("JSON line %d at offset %d: %v", e.Line, e.Offset, e.Err)
+}
+
+func (e *JSONLineError) Unwrap() error {
+	return e.Err
+}
+
+// JSONLinesStream decodes a stream of newline-delimited JSON values, blank lines are skipped. Lines are read
+// as values are requested, so that a slow consumer slows down the producer of the stream rather than having
+// the stream buffered. It is not safe for concurrent use.
+type JSONLinesStream[T any] struct {
+	r           io.Reader
+	br          *bufio.Reader
+	maxLineSize int
+
+	buf    []byte
+	line   int
+	offset int64
+
+	value T
+	err   error
+}
+
+// StreamJSONLines decodes the values of type T of the JSON lines stream r, typically a response body.
+// Lines longer than maxLineSize bytes fail the stream with ErrJSONLineTooLong, a maxLineSize of zero means
+// DefaultMaxJSONLineSize.
+func StreamJSONLines[T any](r io.Reader, maxLineSize int) *JSONLinesStream[T] {
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxJSONLineSize
+	}
+
+	return &JSONLinesStream[T]{
+		r:           r,
+		br:          bufio.NewReader(r),
+		maxLineSize: maxLineSize,
+	}
+}
+
+// Next decodes the next value of the stream, it returns false once the stream ended or failed, Err tells
+// which one
+func (s *JSONLinesStream[T]) Next() bool {
+	for s.err == nil {
+		line, err := s.readLine()
+		s.line++
+		lineOffset := s.offset
+		s.offset += int64(len(line))
+
+		if err != nil && err != io.EOF {
+			if err == ErrJSONLineTooLong {
+				err = &JSONLineError{Line: s.line, Offset: lineOffset, Err: err}
+			}
+			s.err = err
+			return false
+		}
+		// the next call ends the stream
+		s.err = err
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var value T
+		if uErr := json.Unmarshal(line, &value); uErr != nil {
+			s.err = &JSONLineError{Line: s.line, Offset: lineOffset, Err: uErr}
+			return false
+		}
+		s.value = value
+
+		return true
+	}
+
+	return false
+}
+
+// Value returns the value decoded by the last call to Next
+func (s *JSONLinesStream[T]) Value() T {
+	return s.value
+}
+
+// Err returns the error that ended the stream, nil if the stream was read entirely
+func (s *JSONLinesStream[T]) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+
+	return s.err
+}
+
+// Close closes the underlying reader when it is an io.Closer
+func (s *JSONLinesStream[T]) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// readLine reads a line including its newline, the returned slice is valid until the next call
+func (s *JSONLinesStream[T]) readLine() ([]byte, error) {
+	s.buf = s.buf[:0]
+	for {
+		chunk, err := s.br.ReadSlice('\n')
+		s.buf = append(s.buf, chunk...)
+
+		// the newline doesn't count in the size of the line
+		if len(bytes.TrimRight(s.buf, "\r\n")) > s.maxLineSize {
+			return s.buf, ErrJSONLineTooLong
+		}
+		if err != bufio.ErrBufferFull {
+			return s.buf, err
+		}
+	}
+}
+
+// JSONLinesEncoder writes values as newline-delimited JSON
+type JSONLinesEncoder struct {
+	enc *json.Encoder
+}
+
+// NewJSONLinesEncoder returns an encoder writing to w
+func NewJSONLinesEncoder(w io.Writer) *JSONLinesEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	return &JSONLinesEncoder{enc: enc}
+}
+
+// Encode writes v followed by a newline
+func (e *JSONLinesEncoder) Encode(v interface{}) error {
+	return e.enc.Encode(v)
+}
+
+// PostJSONLines sends the values encoded by produce as an application/x-ndjson body. The body is streamed
+// as produce encodes the values, produce is run again to generate the body of each attempt.
+func (c *Client) PostJSONLines(ctx context.Context, url string, produce func(*JSONLinesEncoder) error, opts ...Option) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating request")
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return &producedBody{produce: produce}, nil
+	}
+	req.Body, _ = req.GetBody()
+
+	return c.Do(req, opts...)
+}
+
+// producedBody streams the values encoded by produce, produce is only run once the body is read
+type producedBody struct {
+	produce func(*JSONLinesEncoder) error
+
+	// the transport may close the body while reading it
+	mu     sync.Mutex
+	pr     *io.PipeReader
+	closed bool
+}
+
+func (b *producedBody) reader() (*io.PipeReader, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, io.ErrClosedPipe
+	}
+	if b.pr == nil {
+		pr, pw := io.Pipe()
+		go func() {
+			_ = pw.CloseWithError(b.produce(NewJSONLinesEncoder(pw)))
+		}()
+		b.pr = pr
+	}
+
+	return b.pr, nil
+}
+
+func (b *producedBody) Read(p []byte) (int, error) {
+	pr, err := b.reader()
+	if err != nil {
+		return 0, err
+	}
+
+	return pr.Read(p)
+}
+
+func (b *producedBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	if b.pr == nil {
+		return nil
+	}
+
+	// closing the reader stops produce, which fails writing
+	return b.pr.Close()
+}
@@ -0,0 +1,124 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+type record struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestStreamJSONLines(t *testing.T) {
+	t.Run("Decode the values of a response body", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			_, err := io.WriteString(w, "{\"id\":1,\"name\":\"a\"}\n\n{\"id\":2,\"name\":\"b\"}\r\n{\"id\":3,\"name\":\"c\"}")
+			require.NoError(t, err)
+		}))
+
+		defer server.Close()
+
+		resp, err := client.New().Get(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		stream := client.StreamJSONLines[record](resp.Body, 0)
+		defer stream.Close()
+
+		var records []record
+		for stream.Next() {
+			records = append(records, stream.Value())
+		}
+		require.NoError(t, stream.Err())
+
+		assert.Equal(t, []record{{1, "a"}, {2, "b"}, {3, "c"}}, records)
+	})
+
+	t.Run("Locate invalid lines", func(t *testing.T) {
+		stream := client.StreamJSONLines[record](strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":\n"), 0)
+
+		for stream.Next() {
+		}
+
+		var lineErr *client.JSONLineError
+		require.ErrorAs(t, stream.Err(), &lineErr)
+		assert.Equal(t, 3, lineErr.Line)
+		assert.Equal(t, int64(18), lineErr.Offset)
+	})
+
+	t.Run("Limit the size of lines", func(t *testing.T) {
+		stream := client.StreamJSONLines[record](strings.NewReader("{\"id\":1}\n{\"name\":\""+strings.Repeat("a", 8192)+"\"}\n"), 1024)
+
+		require.True(t, stream.Next())
+		assert.Equal(t, record{ID: 1}, stream.Value())
+
+		assert.False(t, stream.Next())
+		assert.ErrorIs(t, stream.Err(), client.ErrJSONLineTooLong)
+
+		var lineErr *client.JSONLineError
+		require.ErrorAs(t, stream.Err(), &lineErr)
+		assert.Equal(t, 2, lineErr.Line)
+	})
+}
+
+func TestPostJSONLines(t *testing.T) {
+	var attempts atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+
+		stream := client.StreamJSONLines[record](r.Body, 0)
+
+		var records []record
+		for stream.Next() {
+			records = append(records, stream.Value())
+		}
+		require.NoError(t, stream.Err())
+		assert.Equal(t, []record{{1, "a<b>"}, {2, "b"}}, records)
+
+		if attempts.Add(1) == 1 {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer server.Close()
+
+	var produced atomic.Int32
+
+	testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+	resp, err := testClient.PostJSONLines(context.Background(), server.URL, func(enc *client.JSONLinesEncoder) error {
+		produced.Add(1)
+
+		for _, r := range []record{{1, "a<b>"}, {2, "b"}} {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.Equal(t, int32(2), produced.Load())
+}
@@ -0,0 +1,231 @@
+// Package load drives a target with requests sent through a client.Client, at a fixed rate or a fixed
+// concurrency, and reports the throughput, the latency percentiles, the retries and the errors, so that
+// retry policies can be observed under stress.
+package load
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// ErrNoLimit is returned by Run when neither a duration nor a number of requests bounds the load
+var ErrNoLimit = errors.New("load: a duration or a number of requests is required")
+
+// Option configures Run
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) {
+	f(c)
+}
+
+type config struct {
+	rate        float64
+	concurrency int
+	duration    time.Duration
+	requests    int64
+}
+
+// WithRate sends perSecond requests per second, whether the previous requests completed or not. The
+// latency of a request is measured from the time it was scheduled, so that a target slowing down the load
+// shows in the latency rather than hides in a lower rate.
+func WithRate(perSecond float64) Option {
+	return optionFunc(func(c *config) {
+		c.rate = perSecond
+	})
+}
+
+// WithConcurrency sends the requests from n workers, each sending a request once its previous request
+// completed. Combined with WithRate it bounds the requests in flight. One worker is used by default.
+func WithConcurrency(n int) Option {
+	return optionFunc(func(c *config) {
+		c.concurrency = n
+	})
+}
+
+// WithDuration stops sending requests once d elapsed, the requests in flight complete
+func WithDuration(d time.Duration) Option {
+	return optionFunc(func(c *config) {
+		c.duration = d
+	})
+}
+
+// WithRequests stops once n requests were sent
+func WithRequests(n int64) Option {
+	return optionFunc(func(c *config) {
+		c.requests = n
+	})
+}
+
+// Run sends the requests returned by newRequest through c until the duration given by WithDuration
+// elapsed or the number of requests given by WithRequests were sent, at least one of which is required.
+// newRequest is called concurrently with a context it must give to the request. The latency of the
+// requests getting a response, whatever its status, is recorded in the report.
+func Run(ctx context.Context, c *client.Client, newRequest func(context.Context) (*http.Request, error), opts ...Option) (*Report, error) {
+	var cfg config
+	for _, o := range opts {
+		o.apply(&cfg)
+	}
+	if cfg.duration <= 0 && cfg.requests <= 0 {
+		return nil, ErrNoLimit
+	}
+
+	// the run context stops sending requests, the requests in flight complete with ctx
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	if cfg.duration > 0 {
+		runCtx, stop = context.WithTimeout(runCtx, cfg.duration)
+		defer stop()
+	}
+
+	l := &loader{
+		client:     c,
+		newRequest: newRequest,
+		requests:   cfg.requests,
+		stop:       stop,
+		report:     newReport(),
+	}
+
+	start := time.Now()
+	if cfg.rate > 0 {
+		l.runRate(ctx, runCtx, cfg.rate, cfg.concurrency)
+	} else {
+		l.runConcurrency(ctx, runCtx, cfg.concurrency)
+	}
+	l.report.finish(time.Since(start))
+
+	if l.err != nil {
+		return l.report, l.err
+	}
+
+	return l.report, ctx.Err()
+}
+
+// loader sends the requests of a run
+type loader struct {
+	client     *client.Client
+	newRequest func(context.Context) (*http.Request, error)
+	requests   int64
+	stop       context.CancelFunc
+
+	sent   atomic.Int64
+	report *Report
+
+	errOnce sync.Once
+	err     error
+}
+
+// next reports whether another request can be sent
+func (l *loader) next(runCtx context.Context) bool {
+	if runCtx.Err() != nil {
+		return false
+	}
+
+	return l.requests <= 0 || l.sent.Add(1) <= l.requests
+}
+
+// runConcurrency sends requests back to back from concurrency workers
+func (l *loader) runConcurrency(ctx, runCtx context.Context, concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for l.next(runCtx) {
+				l.send(ctx, time.Now())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// runRate sends requests at rate, with at most maxInFlight requests in flight unless it is zero
+func (l *loader) runRate(ctx, runCtx context.Context, rate float64, maxInFlight int) {
+	interval := time.Duration(float64(time.Second) / rate)
+
+	var sem chan struct{}
+	if maxInFlight > 0 {
+		sem = make(chan struct{}, maxInFlight)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for scheduled := time.Now(); ; scheduled = scheduled.Add(interval) {
+		select {
+		case <-timer.C:
+		case <-runCtx.Done():
+			return
+		}
+
+		if !l.next(runCtx) {
+			return
+		}
+
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-runCtx.Done():
+				return
+			}
+		}
+
+		wg.Add(1)
+		go func(scheduled time.Time) {
+			defer wg.Done()
+			if sem != nil {
+				defer func() { <-sem }()
+			}
+
+			l.send(ctx, scheduled)
+		}(scheduled)
+
+		timer.Reset(time.Until(scheduled.Add(interval)))
+	}
+}
+
+// send sends a request scheduled at the time scheduled and records its result
+func (l *loader) send(ctx context.Context, scheduled time.Time) {
+	req, err := l.newRequest(ctx)
+	if err != nil {
+		l.errOnce.Do(func() {
+			l.err = errors.Wrap(err, "error creating request")
+			l.stop()
+		})
+
+		return
+	}
+
+	var attempts int
+	resp, err := l.client.Do(req, client.WithAttemptObserver(func(client.AttemptInfo) {
+		attempts++
+	}))
+	if err == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	l.report.record(time.Since(scheduled), status, attempts, err)
+}
@@ -0,0 +1,137 @@
+package load_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/load"
+)
+
+func getRequest(url string) func(context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(10 * time.Millisecond)
+		case "/flaky":
+			// every other request times out
+			if n%2 == 1 {
+				time.Sleep(100 * time.Millisecond)
+			}
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	reset := func() {
+		atomic.StoreInt32(&requests, 0)
+	}
+
+	t.Run("Send a number of requests from concurrent workers", func(t *testing.T) {
+		defer reset()
+
+		report, err := load.Run(context.Background(), client.New(), getRequest(server.URL+"/slow"),
+			load.WithConcurrency(4), load.WithRequests(40))
+		require.NoError(t, err)
+
+		assert.EqualValues(t, 40, report.Requests)
+		assert.EqualValues(t, 40, atomic.LoadInt32(&requests))
+		assert.Equal(t, map[int]int64{200: 40}, report.Statuses)
+		assert.Zero(t, report.Errors)
+		assert.Zero(t, report.Retries)
+
+		assert.GreaterOrEqual(t, report.Percentile(50), 10*time.Millisecond)
+		assert.GreaterOrEqual(t, report.Latency.P50, 10.0)
+		assert.GreaterOrEqual(t, report.Latency.Max, report.Latency.P99)
+		// 4 workers sending 10 requests each, of 10ms at least
+		assert.GreaterOrEqual(t, report.Duration, 100*time.Millisecond)
+		assert.Greater(t, report.Throughput, 0.0)
+	})
+
+	t.Run("Send requests at a fixed rate for a duration", func(t *testing.T) {
+		defer reset()
+
+		report, err := load.Run(context.Background(), client.New(), getRequest(server.URL),
+			load.WithRate(100), load.WithDuration(200*time.Millisecond))
+		require.NoError(t, err)
+
+		// requests are never sent ahead of their schedule, one every 10ms from the start, but a busy machine
+		// may send fewer
+		assert.Positive(t, report.Requests)
+		assert.LessOrEqual(t, report.Requests, int64(21))
+		assert.EqualValues(t, report.Requests, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("Count retries, statuses and errors", func(t *testing.T) {
+		defer reset()
+
+		// the observers of the client still see every attempt
+		var observed atomic.Int32
+		flakyClient := client.New(client.WithRetryPolicy(20*time.Millisecond, 2), client.WithAttemptObserver(func(client.AttemptInfo) {
+			observed.Add(1)
+		}))
+
+		report, err := load.Run(context.Background(), flakyClient, getRequest(server.URL+"/flaky"), load.WithRequests(10))
+		require.NoError(t, err)
+
+		assert.EqualValues(t, 10, report.Requests)
+		assert.EqualValues(t, 10, report.Retries)
+		assert.Zero(t, report.Errors)
+		assert.EqualValues(t, 20, observed.Load())
+
+		report, err = load.Run(context.Background(), client.New(client.WithRetryPolicy(time.Second, 1)),
+			getRequest(server.URL+"/unavailable"), load.WithRequests(3))
+		require.NoError(t, err)
+		assert.Equal(t, map[int]int64{503: 3}, report.Statuses)
+		assert.Zero(t, report.Errors)
+
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		report, err = load.Run(context.Background(), client.New(client.WithRetryPolicy(time.Second, 1)),
+			getRequest(closed.URL), load.WithRequests(3))
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, report.Errors)
+		assert.Equal(t, map[string]int64{"connection refused": 3}, report.ErrorKinds)
+		assert.Zero(t, report.Latency.Max)
+	})
+
+	t.Run("Write the report", func(t *testing.T) {
+		defer reset()
+
+		report, err := load.Run(context.Background(), client.New(), getRequest(server.URL), load.WithRequests(5))
+		require.NoError(t, err)
+
+		var b bytes.Buffer
+		_, err = report.WriteTo(&b)
+		require.NoError(t, err)
+
+		assert.Contains(t, b.String(), "requests    5 in ")
+		assert.Contains(t, b.String(), "statuses    200: 5\n")
+		assert.Contains(t, b.String(), "latency ms  min ")
+	})
+
+	t.Run("Require a limit", func(t *testing.T) {
+		_, err := load.Run(context.Background(), client.New(), getRequest(server.URL))
+		assert.ErrorIs(t, err, load.ErrNoLimit)
+	})
+}
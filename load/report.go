@@ -0,0 +1,179 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/pkg/errors"
+)
+
+// latencies are recorded in microseconds, from 1µs to an hour, with 3 significant digits
+const (
+	lowestLatency      = 1
+	highestLatency     = int64(time.Hour / time.Microsecond)
+	latencySignificant = 3
+)
+
+// Report sums up a run
+type Report struct {
+	Requests int64 `json:"requests"`
+	// Errors is the number of requests that got no response, or whose response couldn't be read
+	Errors int64 `json:"errors"`
+	// Retries is the number of attempts beyond the first of each request
+	Retries int64 `json:"retries"`
+	// Duration is the duration of the run
+	Duration time.Duration `json:"-"`
+	// Throughput is the number of requests completed per second
+	Throughput float64 `json:"throughput"`
+	// Statuses counts the responses by status
+	Statuses map[int]int64 `json:"statuses"`
+	// ErrorKinds counts the errors by kind, such as timeout or connection refused
+	ErrorKinds map[string]int64 `json:"error_kinds"`
+	Latency    Latency          `json:"latency_ms"`
+
+	mu        sync.Mutex
+	histogram *hdrhistogram.Histogram
+}
+
+// Latency sums up the latency of the requests that got a response, in milliseconds
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	P999 float64 `json:"p99.9"`
+	Max  float64 `json:"max"`
+}
+
+func newReport() *Report {
+	return &Report{
+		Statuses:   make(map[int]int64),
+		ErrorKinds: make(map[string]int64),
+		histogram:  hdrhistogram.New(lowestLatency, highestLatency, latencySignificant),
+	}
+}
+
+// Percentile returns the latency below which percentile percent of the latencies fall
+func (r *Report) Percentile(percentile float64) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return time.Duration(r.histogram.ValueAtPercentile(percentile)) * time.Microsecond
+}
+
+func (r *Report) record(latency time.Duration, status, attempts int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Requests++
+	if attempts > 1 {
+		r.Retries += int64(attempts - 1)
+	}
+	if status != 0 {
+		r.Statuses[status]++
+	}
+
+	if err != nil {
+		r.Errors++
+		r.ErrorKinds[errorKind(err)]++
+
+		return
+	}
+
+	v := int64(latency / time.Microsecond)
+	if v > highestLatency {
+		v = highestLatency
+	}
+	_ = r.histogram.RecordValue(v)
+}
+
+func (r *Report) finish(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Duration = d
+	if d > 0 {
+		r.Throughput = float64(r.Requests) / d.Seconds()
+	}
+
+	ms := func(v int64) float64 {
+		return float64(v) / float64(time.Millisecond/time.Microsecond)
+	}
+	r.Latency = Latency{
+		Min:  ms(r.histogram.Min()),
+		Mean: r.histogram.Mean() / float64(time.Millisecond/time.Microsecond),
+		P50:  ms(r.histogram.ValueAtPercentile(50)),
+		P90:  ms(r.histogram.ValueAtPercentile(90)),
+		P99:  ms(r.histogram.ValueAtPercentile(99)),
+		P999: ms(r.histogram.ValueAtPercentile(99.9)),
+		Max:  ms(r.histogram.Max()),
+	}
+}
+
+// WriteTo writes the report as text
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "requests    %d in %s, %.1f/s\n", r.Requests, r.Duration.Round(time.Millisecond), r.Throughput)
+	fmt.Fprintf(&b, "retries     %d\n", r.Retries)
+
+	statuses := make([]int, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	parts := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		parts = append(parts, fmt.Sprintf("%d: %d", status, r.Statuses[status]))
+	}
+	fmt.Fprintf(&b, "statuses    %s\n", strings.Join(parts, ", "))
+
+	kinds := make([]string, 0, len(r.ErrorKinds))
+	for kind := range r.ErrorKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	parts = parts[:0]
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s: %d", kind, r.ErrorKinds[kind]))
+	}
+	fmt.Fprintf(&b, "errors      %d %s\n", r.Errors, strings.Join(parts, ", "))
+
+	l := r.Latency
+	fmt.Fprintf(&b, "latency ms  min %.2f, mean %.2f, p50 %.2f, p90 %.2f, p99 %.2f, p99.9 %.2f, max %.2f\n",
+		l.Min, l.Mean, l.P50, l.P90, l.P99, l.P999, l.Max)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// errorKind classifies request errors for the error breakdown of the report
+func errorKind(err error) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection closed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	default:
+		return "other"
+	}
+}
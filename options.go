@@ -1,7 +1,12 @@
 package client
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"hash"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/kamilsk/retry/v5/strategy"
@@ -9,8 +14,9 @@ import (
 )
 
 type retryPolicy struct {
-	requestTimeout  time.Duration       // request timeout duration
-	retryStrategies []strategy.Strategy // retry strategies
+	requestTimeout  time.Duration                    // request timeout duration
+	retryStrategies []strategy.Strategy              // retry strategies
+	backOff         func(attempt uint) time.Duration // back off between attempts, waited on the clock
 }
 
 type tracingOptions struct {
@@ -23,6 +29,27 @@ type options struct {
 	operationName  string
 	tracingOptions *tracingOptions
 	retryPolicy    *retryPolicy
+	authenticator  authenticator
+	cacheOptions   *cacheOptions
+	coalescer      *coalescer
+
+	bodyMemoryLimit       int64
+	maxReplayableBodySize int64
+	compressionOptions    *compressionOptions
+	decompressionOptions  *decompressionOptions
+	maxResponseBytes      int64
+
+	downloadOptions   *downloadOptions
+	streamIdleTimeout time.Duration
+	paginationOptions *paginationOptions
+
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+
+	clock           Clock
+	attemptObserver func(AttemptInfo)
+
+	transportOptions *transportOptions
 }
 
 type Option interface {
@@ -41,6 +68,19 @@ func newFuncOption(f func(*options, *rand.Rand)) *funcOption {
 	return &funcOption{f}
 }
 
+// copyOnWrite points *group to a copy of the option group it points to, or to a new group if nil, and returns
+// the copy for modification. Option groups are shared by the concurrent requests of a Client, options never
+// modify them in place.
+func copyOnWrite[T any](group **T) *T {
+	c := new(T)
+	if *group != nil {
+		*c = **group
+	}
+	*group = c
+
+	return c
+}
+
 func WithRetryPolicy(requestTimeout time.Duration, maxRetries uint, strategies ...strategy.Strategy) Option {
 	return newFuncOption(func(o *options, g *rand.Rand) {
 		retryStrategies := make([]strategy.Strategy, 0, len(strategies)+1)
@@ -60,8 +100,8 @@ func WithStandardRetryPolicy(requestTimeout time.Duration, maxRetries uint) Opti
 			requestTimeout: requestTimeout,
 			retryStrategies: []strategy.Strategy{
 				strategy.Limit(maxRetries),
-				StandardBackOffStrategy(stdBackOffExponentialFactor, g, stdBackOffJitterDeviation),
 			},
+			backOff: standardBackOff(stdBackOffExponentialFactor, g, stdBackOffJitterDeviation),
 		}
 	})
 }
@@ -93,3 +133,345 @@ func WithSpanCarrierInjected() Option {
 		}
 	})
 }
+
+// WithBasicAuth sends the username and password with every attempt using HTTP Basic authentication
+func WithBasicAuth(username, password string) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.authenticator = &basicAuth{
+			username: username,
+			password: password,
+		}
+	})
+}
+
+// WithDigestAuth answers HTTP Digest authentication challenges (RFC 7616) with the username and password.
+// When a server responds 401 with a Digest challenge, the request is resent with the computed credentials
+// within the same attempt, the challenge is remembered so that later requests to the same host are
+// authorized preemptively. MD5, SHA-256 and their -sess variants are supported with qop=auth.
+//
+// When passed to New(), the learnt challenges are shared by all requests sent by the Client.
+func WithDigestAuth(username, password string) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.authenticator = newDigestAuth(username, password)
+	})
+}
+
+// WithClientCertificates configures the certificates presented to servers requesting client authentication.
+// It only takes effect when passed to New().
+func WithClientCertificates(certs ...tls.Certificate) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().clientCertificates = certs
+	})
+}
+
+// WithCertReloader presents the certificate maintained by r to servers requesting client authentication,
+// certificates rotated on disk are picked up without recreating the Client. It takes precedence over
+// WithClientCertificates() and only takes effect when passed to New().
+func WithCertReloader(r *CertReloader) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().certReloader = r
+	})
+}
+
+// WithRootCAs configures the certificate authorities used to verify servers instead of the host's root
+// CA set. It only takes effect when passed to New().
+func WithRootCAs(pool *x509.CertPool) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().rootCAs = pool
+	})
+}
+
+// WithMinTLSVersion configures the minimum TLS version accepted, e.g. tls.VersionTLS13, TLS 1.2 is used
+// by default. It only takes effect when passed to New().
+func WithMinTLSVersion(version uint16) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().minTLSVersion = version
+	})
+}
+
+// WithServerName overrides the server name sent in the SNI extension and used to verify the server
+// certificate. It only takes effect when passed to New().
+func WithServerName(serverName string) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().serverName = serverName
+	})
+}
+
+// WithPinnedPublicKeys pins the public keys of host, the TLS handshake with host fails with a
+// PinViolationError unless one of the certificates of the chain verified for the server has a SubjectPublicKeyInfo
+// whose base64 encoded SHA-256 hash is among hashes. It only takes effect when passed to New().
+//
+// The pins are added to a copy of the PinSet given to WithPinSet(), which is left untouched, pins later
+// rotated through that PinSet don't apply to the Client then.
+func WithPinnedPublicKeys(host string, hashes ...string) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		t := o.transport()
+		pinSet := NewPinSet()
+		if t.pinSet != nil {
+			pinSet = t.pinSet.clone()
+		}
+
+		pinSet.Add(host, hashes...)
+		t.pinSet = pinSet
+	})
+}
+
+// WithPinSet enforces the pins of p, pins can be rotated through p while the Client is in use.
+// It only takes effect when passed to New().
+func WithPinSet(p *PinSet) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().pinSet = p
+	})
+}
+
+// WithPinReportOnly stops enforcing public key pins, violations are passed to report instead of failing
+// the TLS handshake. It only takes effect when passed to New().
+func WithPinReportOnly(report func(*PinViolationError)) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().pinReport = report
+	})
+}
+
+// WithProxy sends the requests through the proxy at proxyURL instead of the proxy configured by the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, see ProxyRule for the supported proxy URLs.
+// It only takes effect when passed to New().
+func WithProxy(proxyURL *url.URL) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().proxy = proxyURL
+	})
+}
+
+// WithProxyRules selects the proxy of each request with the first rule matching its host, requests
+// matching no rule use the proxy given to WithProxy(), or the environment proxy configuration.
+// It only takes effect when passed to New().
+func WithProxyRules(rules ...ProxyRule) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		t := o.transport()
+		t.proxyRules = append(append([]ProxyRule(nil), t.proxyRules...), rules...)
+	})
+}
+
+// WithDialContext dials the connections of the Client with dial, e.g. to reach servers through a custom
+// network. It only takes effect when passed to New().
+func WithDialContext(dial DialContextFunc) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().dialContext = dial
+	})
+}
+
+// WithHostDialContext dials the connections to host with dial, host is either a hostname, which covers
+// all ports, or a "host:port" address. It only takes effect when passed to New().
+func WithHostDialContext(host string, dial DialContextFunc) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().setHostDialContext(host, dial)
+	})
+}
+
+// WithUnixSocket connects to the Unix domain socket at path whatever the host of the requests, requests
+// are still addressed with http:// URLs, e.g. "http://docker/v1.43/containers/json".
+// It only takes effect when passed to New().
+func WithUnixSocket(path string) Option {
+	return WithDialContext(UnixSocketDialer(path))
+}
+
+// WithHostUnixSocket connects to the Unix domain socket at path for the requests sent to host, see
+// WithHostDialContext(). It only takes effect when passed to New().
+func WithHostUnixSocket(host, path string) Option {
+	return WithHostDialContext(host, UnixSocketDialer(path))
+}
+
+// WithTransport sends the requests through rt, e.g. a clienttest.MockTransport, instead of a clone of
+// http.DefaultTransport. The other transport options, which configure the default transport, are then
+// ignored. It only takes effect when passed to New().
+func WithTransport(rt http.RoundTripper) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.transport().roundTripper = rt
+	})
+}
+
+// WithCache caches responses in cache as a private cache, see Cache for the caching semantics.
+// Passing a nil cache disables caching, e.g. to bypass the cache of the Client for one request.
+func WithCache(cache Cache) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.cacheOptions = newCacheOptions(cache, false)
+	})
+}
+
+// WithSharedCache caches responses in cache as a shared cache: s-maxage is honored while responses
+// marked private and responses to authorized requests are not stored unless explicitly allowed
+func WithSharedCache(cache Cache) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.cacheOptions = newCacheOptions(cache, true)
+	})
+}
+
+// WithStaleWhileRevalidate serves cached responses up to window after they become stale while they are
+// revalidated in the background, unless the response carries its own stale-while-revalidate directive
+func WithStaleWhileRevalidate(window time.Duration) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.staleWhileRevalidate = window
+	})
+}
+
+// WithStaleIfError serves cached responses up to window after they become stale when the server cannot be
+// reached after all retries or responds with a 500, 502, 503 or 504 status, unless the response carries
+// its own stale-if-error directive
+func WithStaleIfError(window time.Duration) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.staleIfError = window
+	})
+}
+
+// WithRequestCoalescing collapses identical concurrent GET and HEAD requests into one upstream request
+// whose response is fanned out to all callers, each caller receives a copy of the response with its own
+// readable body. Requests are identical when they share method, URL, credentials and the values of headers,
+// credentials being the Authorization, Proxy-Authorization and Cookie headers and the authentication option.
+//
+// The response is read in full before being fanned out, hence Range requests and requests accepting
+// text/event-stream, such as the ones of Events(), are never coalesced. Other long-lived responses should
+// not be requested through a coalescing Client.
+//
+// A caller whose context is canceled stops waiting without affecting the other callers, the upstream
+// request is canceled once all of its callers stopped waiting. Requests are coalesced with the other
+// requests of the Client, hence the option is meant to be passed to New().
+func WithRequestCoalescing(headers ...string) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.coalescer = newCoalescer(headers)
+	})
+}
+
+// WithRequestBodySpooling bounds the memory used to keep non seekable request bodies for retries: bodies
+// larger than memoryLimit bytes are spooled to a temporary file removed once the request completes, bodies
+// larger than maxReplayableSize bytes are streamed once and the request is not retried. A limit of zero
+// means no limit, by default bodies are buffered in memory whatever their size.
+//
+// Bodies of requests setting GetBody, such as the ones built by http.NewRequest from a *bytes.Buffer,
+// *bytes.Reader or *strings.Reader, and seekable bodies, such as BytesReadSeekCloser, are never buffered.
+func WithRequestBodySpooling(memoryLimit, maxReplayableSize int64) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.bodyMemoryLimit = memoryLimit
+		o.maxReplayableBodySize = maxReplayableSize
+	})
+}
+
+// WithRequestCompression compresses request bodies of at least minSize bytes with the content coding
+// encoding, gzip and zstd are built in and other codings can be added with RegisterEncoder. Bodies are
+// compressed once and reused across retries, requests already setting Content-Encoding are sent as is.
+// When the server answers 415 Unsupported Media Type, the request is sent again uncompressed.
+//
+// Passing an empty encoding disables compression.
+func WithRequestCompression(encoding string, minSize int64) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		if encoding == "" {
+			o.compressionOptions = nil
+			return
+		}
+
+		o.compressionOptions = &compressionOptions{
+			encoding: encoding,
+			minSize:  minSize,
+		}
+	})
+}
+
+// WithResponseDecompression advertises the br, zstd, gzip and deflate content codings, and the ones added
+// with RegisterDecoder, then transparently decodes the response bodies using them. Reading more than
+// maxSize decoded bytes fails with ErrDecompressedBodyTooLarge to defend against compression bombs, a
+// maxSize of zero means no limit.
+//
+// Requests already setting Accept-Encoding are sent as is, their responses are decoded as well when
+// their content codings are known. Range requests and partial responses are never decoded.
+func WithResponseDecompression(maxSize int64) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.decompressionOptions = &decompressionOptions{
+			maxSize: maxSize,
+		}
+	})
+}
+
+// WithMaxResponseBytes limits the size of response bodies, reading more than n bytes of a body fails with
+// ErrResponseTooLarge. The limit applies to decoded bodies when WithResponseDecompression is set, a limit
+// of zero means no limit.
+func WithMaxResponseBytes(n int64) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.maxResponseBytes = n
+	})
+}
+
+// WithDownloadChunks splits downloads into at most chunks ranges of at least minChunkSize bytes fetched in
+// parallel, the destination of the download must then support concurrent writes. Downloads fall back to a
+// single transfer when the server doesn't advertise range support, the size or a validator of the resource.
+func WithDownloadChunks(chunks int, minChunkSize int64) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		d := o.download()
+		d.chunks = chunks
+		d.minChunkSize = minChunkSize
+	})
+}
+
+// WithDownloadProgress reports the progress of downloads, progress is called after each write to the
+// destination with the number of bytes written so far and the size of the resource, -1 when unknown
+func WithDownloadProgress(progress func(written, total int64)) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.download().progress = progress
+	})
+}
+
+// WithDownloadChecksum verifies that the checksum of downloaded content, computed by a hash returned by
+// newHash, equals checksum. Downloads not matching the checksum fail with ErrChecksumMismatch.
+func WithDownloadChecksum(newHash func() hash.Hash, checksum []byte) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		d := o.download()
+		d.newHash = newHash
+		d.checksum = checksum
+	})
+}
+
+// WithStreamIdleTimeout sets how long a stream, such as the one returned by Events, may go without receiving
+// any data before it is reconnected. It defaults to two minutes, the request timeout of the retry policy
+// doesn't apply to streams.
+func WithStreamIdleTimeout(timeout time.Duration) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.streamIdleTimeout = timeout
+	})
+}
+
+// WithMaxPages stops Paginate after n pages, a limit of zero means no limit
+func WithMaxPages(n int) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.pagination().maxPages = n
+	})
+}
+
+// WithPagePrefetch makes Paginate fetch the next page in the background as soon as the current page is
+// returned
+func WithPagePrefetch() Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.pagination().prefetch = true
+	})
+}
+
+// WithClock times the client with clock instead of the real clock, see Clock. Strategies given to
+// WithRetryPolicy wait on their own timers, only the back off of WithStandardRetryPolicy waits on clock.
+func WithClock(clock Clock) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.clock = clock
+	})
+}
+
+// WithAttemptObserver calls observe after each attempt of a request, successful or not, observe is called
+// by the goroutine sending the request. Observers add up, those set earlier, e.g. on the client rather than
+// the request, are called first.
+func WithAttemptObserver(observe func(AttemptInfo)) Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		if previous := o.attemptObserver; previous != nil {
+			o.attemptObserver = func(info AttemptInfo) {
+				previous(info)
+				observe(info)
+			}
+			return
+		}
+
+		o.attemptObserver = observe
+	})
+}
@@ -0,0 +1,350 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PaginationStrategy finds the page following a response of a listing endpoint
+type PaginationStrategy interface {
+	// Next returns the request of the page following resp, the response to req whose body has already been
+	// read, or nil when resp is the last page
+	Next(req *http.Request, resp *http.Response, body []byte) (*http.Request, error)
+}
+
+// PaginationFunc is a PaginationStrategy implemented by a function
+type PaginationFunc func(req *http.Request, resp *http.Response, body []byte) (*http.Request, error)
+
+func (f PaginationFunc) Next(req *http.Request, resp *http.Response, body []byte) (*http.Request, error) {
+	return f(req, resp, body)
+}
+
+// LinkPagination follows the next links of the Link headers of the responses, as defined by RFC 8288
+func LinkPagination() PaginationStrategy {
+	return PaginationFunc(func(req *http.Request, resp *http.Response, _ []byte) (*http.Request, error) {
+		next, ok := parseLinks(resp.Header.Values("Link"))["next"]
+		if !ok {
+			return nil, nil
+		}
+
+		u, err := req.URL.Parse(next)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing next link")
+		}
+
+		return nextPageRequest(req, u), nil
+	})
+}
+
+// CursorPagination sets the query parameter param to the cursor extract finds in the body of the responses,
+// the last page has no cursor
+func CursorPagination(param string, extract func(body []byte) (string, error)) PaginationStrategy {
+	return PaginationFunc(func(req *http.Request, _ *http.Response, body []byte) (*http.Request, error) {
+		cursor, err := extract(body)
+		if err != nil || cursor == "" {
+			return nil, err
+		}
+
+		return withQueryParam(req, param, cursor), nil
+	})
+}
+
+// PageNumberPagination increments the query parameter param, starting from 1 when it isn't set, until count
+// finds no item in the body of a response
+func PageNumberPagination(param string, count func(body []byte) (int, error)) PaginationStrategy {
+	return PaginationFunc(func(req *http.Request, _ *http.Response, body []byte) (*http.Request, error) {
+		n, err := count(body)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+
+		page := 1
+		if v := req.URL.Query().Get(param); v != "" {
+			if page, err = strconv.Atoi(v); err != nil {
+				return nil, errors.Wrapf(err, "error parsing %s parameter", param)
+			}
+		}
+
+		return withQueryParam(req, param, strconv.Itoa(page+1)), nil
+	})
+}
+
+// OffsetPagination advances the query parameter param, starting from 0 when it isn't set, by the number of
+// items count finds in the body of each response, until a response has none
+func OffsetPagination(param string, count func(body []byte) (int, error)) PaginationStrategy {
+	return PaginationFunc(func(req *http.Request, _ *http.Response, body []byte) (*http.Request, error) {
+		n, err := count(body)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+
+		offset := 0
+		if v := req.URL.Query().Get(param); v != "" {
+			if offset, err = strconv.Atoi(v); err != nil {
+				return nil, errors.Wrapf(err, "error parsing %s parameter", param)
+			}
+		}
+
+		return withQueryParam(req, param, strconv.Itoa(offset+n)), nil
+	})
+}
+
+func withQueryParam(req *http.Request, param, value string) *http.Request {
+	u := *req.URL
+	query := u.Query()
+	query.Set(param, value)
+	u.RawQuery = query.Encode()
+
+	return nextPageRequest(req, &u)
+}
+
+// nextPageRequest returns a request for the page at u with the context and headers of req, like redirects
+// followed by http.Client, the credentials of req are not sent to another host
+func nextPageRequest(req *http.Request, u *url.URL) *http.Request {
+	next := req.Clone(req.Context())
+	next.URL = u
+	next.Host = ""
+
+	if !strings.EqualFold(u.Host, req.URL.Host) {
+		for _, h := range []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2"} {
+			next.Header.Del(h)
+		}
+	}
+
+	return next
+}
+
+// parseLinks returns the targets of the Link header values by relation type
+func parseLinks(values []string) map[string]string {
+	links := make(map[string]string)
+
+	for _, value := range values {
+		for value != "" {
+			start := strings.IndexByte(value, '<')
+			end := strings.IndexByte(value, '>')
+			if start < 0 || end < start {
+				break
+			}
+			target := value[start+1 : end]
+			value = value[end+1:]
+
+			// the parameters of the link run up to the next link
+			params := value
+			if next := strings.IndexByte(value, '<'); next >= 0 {
+				params, value = value[:next], value[next:]
+			} else {
+				value = ""
+			}
+
+			for _, s := range splitOutsideQuotes(params, ';') {
+				name, v, ok := strings.Cut(s.text, "=")
+				if !ok || !strings.EqualFold(strings.TrimSpace(name), "rel") {
+					continue
+				}
+
+				v = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(v), ","))
+				if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+					v = unquote(v[1 : len(v)-1])
+				}
+				for _, rel := range strings.Fields(v) {
+					if _, ok := links[strings.ToLower(rel)]; !ok {
+						links[strings.ToLower(rel)] = target
+					}
+				}
+			}
+		}
+	}
+
+	return links
+}
+
+// Page is a page of a listing endpoint
+type Page struct {
+	// Number is the number of the page, starting from 1
+	Number int
+	// Response is the response of the page, its body has already been read and closed
+	Response *http.Response
+	// Body is the body of the response
+	Body []byte
+}
+
+// Paginator iterates over the pages of a listing endpoint. It is not safe for concurrent use.
+type Paginator struct {
+	client   *Client
+	strategy PaginationStrategy
+	opts     []Option
+	maxPages int
+	prefetch bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	next    *http.Request
+	pending *pageFetch
+	page    *Page
+	err     error
+}
+
+// pageFetch is the fetch of a page, possibly running in the background
+type pageFetch struct {
+	req  *http.Request
+	done chan struct{}
+	page *Page
+	err  error
+}
+
+// Paginate iterates over the pages of the listing endpoint at url, strategy finds the page following each
+// page. Each page is sent through Do, with retries. WithMaxPages bounds the number of pages and
+// WithPagePrefetch fetches the next page while the current one is processed.
+func (c *Client) Paginate(ctx context.Context, url string, strategy PaginationStrategy, opts ...Option) *Paginator {
+	requestOpts := c.options
+	for _, o := range opts {
+		o.apply(&requestOpts, c.generator)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Paginator{
+		client:   c,
+		strategy: strategy,
+		opts:     opts,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	if requestOpts.paginationOptions != nil {
+		p.maxPages = requestOpts.paginationOptions.maxPages
+		p.prefetch = requestOpts.paginationOptions.prefetch
+	}
+
+	p.next, p.err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if p.err != nil {
+		p.err = errors.Wrap(p.err, "error creating request")
+	}
+
+	return p
+}
+
+type paginationOptions struct {
+	maxPages int
+	prefetch bool
+}
+
+// pagination returns the pagination options for modification
+func (o *options) pagination() *paginationOptions {
+	return copyOnWrite(&o.paginationOptions)
+}
+
+// Next fetches the next page, it returns false once the last page was reached or the pagination failed, Err
+// tells which one
+func (p *Paginator) Next() bool {
+	if p.err != nil {
+		return false
+	}
+
+	if p.pending == nil {
+		if p.next == nil {
+			return false
+		}
+		p.pending = p.fetch(p.next, p.pageNumber()+1)
+	}
+
+	fetch := p.pending
+	p.pending = nil
+
+	select {
+	case <-fetch.done:
+	case <-p.ctx.Done():
+		p.err = p.ctx.Err()
+		return false
+	}
+	if fetch.err != nil {
+		p.err = fetch.err
+		return false
+	}
+	p.page = fetch.page
+
+	p.next = nil
+	if p.maxPages <= 0 || p.page.Number < p.maxPages {
+		next, err := p.strategy.Next(fetch.req, p.page.Response, p.page.Body)
+		if err != nil {
+			p.err = errors.Wrap(err, "error finding next page")
+			return false
+		}
+		p.next = next
+	}
+
+	if p.prefetch && p.next != nil {
+		p.pending = p.fetch(p.next, p.page.Number+1)
+	}
+
+	return true
+}
+
+// Page returns the page fetched by the last call to Next
+func (p *Paginator) Page() *Page {
+	return p.page
+}
+
+// Err returns the error that ended the pagination, nil if the last page was reached
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// Close stops the pagination, canceling the page being prefetched
+func (p *Paginator) Close() {
+	p.cancel()
+	if p.err == nil && (p.next != nil || p.pending != nil) {
+		p.err = context.Canceled
+	}
+}
+
+func (p *Paginator) pageNumber() int {
+	if p.page == nil {
+		return 0
+	}
+
+	return p.page.Number
+}
+
+// fetch fetches the page of req in the background
+func (p *Paginator) fetch(req *http.Request, number int) *pageFetch {
+	f := &pageFetch{
+		req:  req,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(f.done)
+
+		resp, err := p.client.Do(req, p.opts...)
+		if err != nil {
+			f.err = err
+			return
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			f.err = errors.Wrap(err, "error reading page")
+			return
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			f.err = errors.Errorf("unexpected status %q fetching page %d", resp.Status, number)
+			return
+		}
+
+		f.page = &Page{
+			Number:   number,
+			Response: resp,
+			Body:     body,
+		}
+	}()
+
+	return f
+}
@@ -0,0 +1,187 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+type itemsPage struct {
+	Items []int  `json:"items"`
+	Next  string `json:"next,omitempty"`
+}
+
+func countItems(body []byte) (int, error) {
+	var page itemsPage
+	err := json.Unmarshal(body, &page)
+
+	return len(page.Items), err
+}
+
+// newListingServer serves 7 items, 3 per page, paginated by the page, offset, cursor query parameters and
+// Link headers
+func newListingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	const (
+		total    = 7
+		pageSize = 3
+	)
+
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		query := r.URL.Query()
+		offset := 0
+		switch {
+		case query.Get("page") != "":
+			page, err := strconv.Atoi(query.Get("page"))
+			require.NoError(t, err)
+			offset = (page - 1) * pageSize
+		case query.Get("offset") != "":
+			var err error
+			offset, err = strconv.Atoi(query.Get("offset"))
+			require.NoError(t, err)
+		case query.Get("cursor") != "":
+			var err error
+			offset, err = strconv.Atoi(query.Get("cursor")[len("c"):])
+			require.NoError(t, err)
+		}
+
+		page := itemsPage{Items: []int{}}
+		for i := offset; i < offset+pageSize && i < total; i++ {
+			page.Items = append(page.Items, i)
+		}
+		if offset+pageSize < total {
+			page.Next = fmt.Sprintf("c%d", offset+pageSize)
+			w.Header().Add("Link", fmt.Sprintf(`</items?offset=%d>; rel="next", </items?offset=6>; rel="last"`, offset+pageSize))
+		}
+
+		require.NoError(t, json.NewEncoder(w).Encode(page))
+	}))
+
+	return server, &requests
+}
+
+func collectItems(t *testing.T, paginator *client.Paginator) ([]int, []int) {
+	var items, numbers []int
+	for paginator.Next() {
+		var page itemsPage
+		require.NoError(t, json.Unmarshal(paginator.Page().Body, &page))
+
+		items = append(items, page.Items...)
+		numbers = append(numbers, paginator.Page().Number)
+	}
+	require.NoError(t, paginator.Err())
+
+	return items, numbers
+}
+
+func TestPaginate(t *testing.T) {
+	server, requests := newListingServer(t)
+
+	defer server.Close()
+
+	testClient := client.New()
+
+	strategies := map[string]client.PaginationStrategy{
+		"link": client.LinkPagination(),
+		"cursor": client.CursorPagination("cursor", func(body []byte) (string, error) {
+			var page itemsPage
+			err := json.Unmarshal(body, &page)
+
+			return page.Next, err
+		}),
+		"page":   client.PageNumberPagination("page", countItems),
+		"offset": client.OffsetPagination("offset", countItems),
+	}
+
+	for name, strategy := range strategies {
+		t.Run("Iterate over pages with the "+name+" strategy", func(t *testing.T) {
+			paginator := testClient.Paginate(context.Background(), server.URL+"/items", strategy)
+			defer paginator.Close()
+
+			items, numbers := collectItems(t, paginator)
+			assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6}, items)
+			assert.Equal(t, numbers[len(numbers)-1], len(numbers))
+		})
+	}
+
+	t.Run("Stop after the maximum number of pages", func(t *testing.T) {
+		requests.Store(0)
+
+		paginator := testClient.Paginate(context.Background(), server.URL+"/items", client.LinkPagination(),
+			client.WithMaxPages(2), client.WithPagePrefetch())
+		defer paginator.Close()
+
+		items, numbers := collectItems(t, paginator)
+		assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, items)
+		assert.Equal(t, []int{1, 2}, numbers)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("Prefetch the next page", func(t *testing.T) {
+		requests.Store(0)
+
+		paginator := testClient.Paginate(context.Background(), server.URL+"/items", client.LinkPagination(),
+			client.WithPagePrefetch())
+		defer paginator.Close()
+
+		require.True(t, paginator.Next())
+
+		assert.Eventually(t, func() bool { return requests.Load() == 2 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Fail on unsuccessful pages", func(t *testing.T) {
+		brokenServer := httptest.NewServer(http.NotFoundHandler())
+		defer brokenServer.Close()
+
+		paginator := testClient.Paginate(context.Background(), brokenServer.URL, client.LinkPagination())
+		defer paginator.Close()
+
+		assert.False(t, paginator.Next())
+		assert.Error(t, paginator.Err())
+	})
+}
+
+func TestLinkPagination(t *testing.T) {
+	t.Run("Don't send credentials to other hosts", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://api.example.com/items", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Cookie", "session=s")
+		req.Header.Set("Accept", "application/json")
+
+		next := func(link string) *http.Request {
+			resp := &http.Response{Header: http.Header{"Link": {link}}, Request: req}
+
+			next, err := client.LinkPagination().Next(req, resp, nil)
+			require.NoError(t, err)
+
+			return next
+		}
+
+		sameHost := next(`</items?page=2>; rel="next"`)
+		assert.Equal(t, "Bearer token", sameHost.Header.Get("Authorization"))
+		assert.Equal(t, "session=s", sameHost.Header.Get("Cookie"))
+
+		otherHost := next(`<https://cdn.example.net/items?page=2>; rel="next"`)
+		assert.Equal(t, "https://cdn.example.net/items?page=2", otherHost.URL.String())
+		assert.Empty(t, otherHost.Header.Get("Authorization"))
+		assert.Empty(t, otherHost.Header.Get("Cookie"))
+		assert.Equal(t, "application/json", otherHost.Header.Get("Accept"))
+
+		// the headers of the original request are left untouched
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	})
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const pinHashPrefix = "sha256/"
+
+// PinViolationError is returned when none of the public keys presented by a server matches the keys
+// pinned for its host. Do never retries a request failing with a PinViolationError.
+type PinViolationError struct {
+	Host string
+	// Hashes are the base64 encoded SHA-256 hashes of the SubjectPublicKeyInfo of the certificates
+	// of the chains verified for the server
+	Hashes []string
+}
+
+func (e *PinViolationError) Error() string {
+	return fmt.Sprintf("public key pin violation for host %q, presented keys: %s", e.Host, strings.Join(e.Hashes, ", "))
+}
+
+// PinSet holds the SPKI pins of hosts, a pin is the base64 encoded SHA-256 hash of a certificate's
+// SubjectPublicKeyInfo, optionally prefixed with "sha256/". A host is either a hostname or a wildcard
+// covering one label, e.g. "*.example.com", it is matched against the TLS server name of connections,
+// which is not sent for IP addresses unless overridden with WithServerName().
+//
+// A PinSet is safe for concurrent use, pins can be rotated with Set() while the Client is in use, the
+// new pins apply to subsequent TLS handshakes.
+type PinSet struct {
+	mu   sync.RWMutex
+	pins map[string]map[string]struct{}
+}
+
+func NewPinSet() *PinSet {
+	return &PinSet{pins: make(map[string]map[string]struct{})}
+}
+
+// Add pins additional public keys of host, e.g. backup keys for a planned rotation
+func (p *PinSet) Add(host string, hashes ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	host = strings.ToLower(host)
+	if p.pins[host] == nil {
+		p.pins[host] = make(map[string]struct{}, len(hashes))
+	}
+
+	p.add(host, hashes)
+}
+
+func (p *PinSet) add(host string, hashes []string) {
+	for _, h := range hashes {
+		p.pins[host][strings.TrimPrefix(h, pinHashPrefix)] = struct{}{}
+	}
+}
+
+// Set replaces the pins of host
+func (p *PinSet) Set(host string, hashes ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the pins are replaced under a single lock, so that no handshake sees the host unpinned
+	host = strings.ToLower(host)
+	p.pins[host] = make(map[string]struct{}, len(hashes))
+	p.add(host, hashes)
+}
+
+// Remove unpins host
+func (p *PinSet) Remove(host string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pins, strings.ToLower(host))
+}
+
+// clone returns a copy of p
+func (p *PinSet) clone() *PinSet {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	c := &PinSet{pins: make(map[string]map[string]struct{}, len(p.pins))}
+	for host, hashes := range p.pins {
+		c.pins[host] = make(map[string]struct{}, len(hashes))
+		for h := range hashes {
+			c.pins[host][h] = struct{}{}
+		}
+	}
+
+	return c
+}
+
+// verify checks the certificates of the verified chains of a connection against the pins of its server
+// name, hosts without pins are not verified. The certificates presented by the server are not checked
+// themselves, as a server holding any trusted certificate could present a pinned certificate along with it.
+func (p *PinSet) verify(cs tls.ConnectionState) error {
+	host := strings.ToLower(cs.ServerName)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	pins, ok := p.pins[host]
+	if !ok {
+		if i := strings.IndexByte(host, '.'); i >= 0 {
+			pins, ok = p.pins["*"+host[i:]]
+		}
+	}
+
+	if !ok {
+		return nil
+	}
+
+	var hashes []string
+	seen := make(map[string]struct{})
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			hash := base64.StdEncoding.EncodeToString(sum[:])
+
+			if _, match := pins[hash]; match {
+				return nil
+			}
+
+			if _, ok := seen[hash]; !ok {
+				seen[hash] = struct{}{}
+				hashes = append(hashes, hash)
+			}
+		}
+	}
+
+	return &PinViolationError{Host: host, Hashes: hashes}
+}
+
+// verifyConnection returns a tls.Config.VerifyConnection function enforcing the pins, if report is not
+// nil the pins are not enforced, violations are passed to report and the connection is allowed
+func (p *PinSet) verifyConnection(report func(*PinViolationError)) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		err := p.verify(cs)
+		if err == nil || report == nil {
+			return err
+		}
+
+		report(err.(*PinViolationError))
+
+		return nil
+	}
+}
@@ -0,0 +1,252 @@
+package client_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+const (
+	wrongPin = "sha256/AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	// pins are matched against the TLS server name, which is not sent for IP addresses, the certificate
+	// of httptest servers is valid for example.com
+	pinnedHost = "example.com"
+)
+
+// newPinnedServer starts a TLS server counting its connections, it returns the server, a pool trusting
+// its certificate and the pin of its public key
+func newPinnedServer() (*httptest.Server, *x509.CertPool, string, *atomic.Int32) {
+	var connCount atomic.Int32
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connCount.Add(1)
+		}
+	}
+	server.StartTLS()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+
+	return server, pool, pin, &connCount
+}
+
+// newSelfSignedCertificate returns a self-signed certificate, trusted by no client, and the pin of its key
+func newSelfSignedCertificate(t *testing.T) ([]byte, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: pinnedHost},
+		DNSNames:     []string{pinnedHost},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return der, "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestPinnedPublicKeys(t *testing.T) {
+	t.Run("Succeed when the server key is pinned", func(t *testing.T) {
+		server, pool, pin, _ := newPinnedServer()
+
+		defer server.Close()
+
+		testClient := client.New(
+			client.WithRootCAs(pool),
+			client.WithServerName(pinnedHost),
+			client.WithPinnedPublicKeys(pinnedHost, wrongPin, pin),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("Fail without retrying when no key matches", func(t *testing.T) {
+		server, pool, _, connCount := newPinnedServer()
+
+		defer server.Close()
+
+		testClient := client.New(
+			client.WithRootCAs(pool),
+			client.WithServerName(pinnedHost),
+			client.WithPinnedPublicKeys(pinnedHost, wrongPin),
+			client.WithRetryPolicy(time.Second, 5),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL) //nolint: bodyclose
+		require.Error(t, err)
+		assert.Nil(t, resp)
+
+		var pinErr *client.PinViolationError
+		require.True(t, errors.As(err, &pinErr))
+		assert.Equal(t, pinnedHost, pinErr.Host)
+		assert.Equal(t, int32(1), connCount.Load())
+	})
+
+	t.Run("Ignore pinned keys presented outside of the verified chain", func(t *testing.T) {
+		// the certificate and key of httptest servers
+		template := httptest.NewTLSServer(http.NotFoundHandler())
+		certificate := template.TLS.Certificates[0]
+		template.Close()
+
+		extra, pin := newSelfSignedCertificate(t)
+
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		server.TLS = &tls.Config{
+			Certificates: []tls.Certificate{{
+				Certificate: [][]byte{certificate.Certificate[0], extra},
+				PrivateKey:  certificate.PrivateKey,
+			}},
+		}
+		server.StartTLS()
+
+		defer server.Close()
+
+		pool := x509.NewCertPool()
+		pool.AddCert(server.Certificate())
+
+		testClient := client.New(
+			client.WithRootCAs(pool),
+			client.WithServerName(pinnedHost),
+			client.WithPinnedPublicKeys(pinnedHost, pin),
+			client.WithRetryPolicy(time.Second, 1),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL) //nolint: bodyclose
+		require.Error(t, err)
+		assert.Nil(t, resp)
+
+		var pinErr *client.PinViolationError
+		require.True(t, errors.As(err, &pinErr))
+		assert.NotContains(t, pinErr.Hashes, strings.TrimPrefix(pin, "sha256/"))
+	})
+
+	t.Run("Report violations without failing in report only mode", func(t *testing.T) {
+		server, pool, _, _ := newPinnedServer()
+
+		defer server.Close()
+
+		var violations atomic.Int32
+
+		testClient := client.New(
+			client.WithRootCAs(pool),
+			client.WithServerName(pinnedHost),
+			client.WithPinnedPublicKeys(pinnedHost, wrongPin),
+			client.WithPinReportOnly(func(err *client.PinViolationError) {
+				violations.Add(1)
+			}),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(1), violations.Load())
+	})
+
+	t.Run("Rotate pins while the client is in use", func(t *testing.T) {
+		server, pool, pin, _ := newPinnedServer()
+
+		defer server.Close()
+
+		pins := client.NewPinSet()
+		pins.Set(pinnedHost, wrongPin)
+
+		testClient := client.New(
+			client.WithRootCAs(pool),
+			client.WithServerName(pinnedHost),
+			client.WithPinSet(pins),
+			client.WithRetryPolicy(time.Second, 1),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL) //nolint: bodyclose
+		require.Error(t, err)
+		assert.Nil(t, resp)
+
+		// the pin may be given without the sha256/ prefix
+		pins.Set(pinnedHost, strings.TrimPrefix(pin, "sha256/"))
+
+		resp, err = testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("Add pins to a copy of the pin set", func(t *testing.T) {
+		server, pool, pin, _ := newPinnedServer()
+
+		defer server.Close()
+
+		pins := client.NewPinSet()
+		pins.Set(pinnedHost, wrongPin)
+
+		pinnedClient := client.New(
+			client.WithRootCAs(pool),
+			client.WithServerName(pinnedHost),
+			client.WithPinSet(pins),
+			client.WithPinnedPublicKeys(pinnedHost, pin),
+		)
+
+		resp, err := pinnedClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		// the pin added to the first client doesn't apply to the clients sharing the pin set
+		testClient := client.New(
+			client.WithRootCAs(pool),
+			client.WithServerName(pinnedHost),
+			client.WithPinSet(pins),
+			client.WithRetryPolicy(time.Second, 1),
+		)
+
+		resp, err = testClient.Get(context.Background(), server.URL) //nolint: bodyclose
+		require.Error(t, err)
+		assert.Nil(t, resp)
+	})
+}
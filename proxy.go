@@ -0,0 +1,69 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ProxyRule routes the requests whose host matches Match through Proxy, a nil Proxy sends the
+// requests directly. Match is either a host glob in path.Match syntax, e.g. "*.example.com", or a
+// CIDR, e.g. "10.0.0.0/8", which only matches hosts given as IP addresses.
+//
+// Proxy URLs with the http and https schemes are used as HTTP proxies, https targets are tunneled
+// with CONNECT, socks5 and socks5h URLs are used as SOCKS5 proxies. Credentials in the user info of
+// the proxy URL authenticate the client to the proxy.
+type ProxyRule struct {
+	Match string
+	Proxy *url.URL
+}
+
+type proxyRule struct {
+	glob  string
+	ipNet *net.IPNet
+	proxy *url.URL
+}
+
+func (r *proxyRule) matches(host string) bool {
+	if r.ipNet != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && r.ipNet.Contains(ip)
+	}
+
+	// a malformed glob never matches
+	ok, _ := path.Match(r.glob, strings.ToLower(host))
+
+	return ok
+}
+
+// newProxyFunc returns the http.Transport.Proxy function selecting the proxy of a request, the first
+// matching rule wins, requests matching no rule use defaultProxy, or the proxy configured by the
+// environment variables if defaultProxy is nil
+func newProxyFunc(defaultProxy *url.URL, rules []ProxyRule) func(*http.Request) (*url.URL, error) {
+	compiled := make([]proxyRule, 0, len(rules))
+	for _, r := range rules {
+		pr := proxyRule{glob: strings.ToLower(r.Match), proxy: r.Proxy}
+		if _, ipNet, err := net.ParseCIDR(r.Match); err == nil {
+			pr.ipNet = ipNet
+		}
+
+		compiled = append(compiled, pr)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		host := req.URL.Hostname()
+		for i := range compiled {
+			if compiled[i].matches(host) {
+				return compiled[i].proxy, nil
+			}
+		}
+
+		if defaultProxy != nil {
+			return defaultProxy, nil
+		}
+
+		return http.ProxyFromEnvironment(req)
+	}
+}
@@ -0,0 +1,238 @@
+package client_test
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+type testHTTPProxy struct {
+	t             *testing.T
+	requests      atomic.Int32
+	authorization atomic.Value
+}
+
+func (p *testHTTPProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.requests.Add(1)
+	p.authorization.Store(r.Header.Get("Proxy-Authorization"))
+
+	if r.Method == http.MethodConnect {
+		target, err := net.Dial("tcp", r.Host)
+		require.NoError(p.t, err)
+
+		w.WriteHeader(http.StatusOK)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(p.t, err)
+
+		go pipe(conn, target)
+
+		return
+	}
+
+	r.RequestURI = ""
+	r.Header.Del("Proxy-Authorization")
+
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	require.NoError(p.t, err)
+
+	defer resp.Body.Close()
+
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+func pipe(a, b net.Conn) {
+	go func() {
+		_, _ = io.Copy(a, b)
+		a.Close()
+	}()
+
+	_, _ = io.Copy(b, a)
+	b.Close()
+}
+
+// serveSOCKS5 serves the CONNECT command of SOCKS5 clients authenticating with username and password
+func serveSOCKS5(t *testing.T, l net.Listener, username, password string, requests *atomic.Int32) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			buf := make([]byte, 256)
+
+			// greeting: version, methods, the username/password method is selected
+			_, err := io.ReadFull(conn, buf[:2])
+			require.NoError(t, err)
+			_, err = io.ReadFull(conn, buf[:buf[1]])
+			require.NoError(t, err)
+			_, err = conn.Write([]byte{5, 2})
+			require.NoError(t, err)
+
+			// username/password sub-negotiation
+			_, err = io.ReadFull(conn, buf[:2])
+			require.NoError(t, err)
+			user := make([]byte, buf[1])
+			_, err = io.ReadFull(conn, user)
+			require.NoError(t, err)
+			_, err = io.ReadFull(conn, buf[:1])
+			require.NoError(t, err)
+			pass := make([]byte, buf[0])
+			_, err = io.ReadFull(conn, pass)
+			require.NoError(t, err)
+
+			if string(user) != username || string(pass) != password {
+				_, _ = conn.Write([]byte{1, 1})
+				conn.Close()
+				return
+			}
+			_, err = conn.Write([]byte{1, 0})
+			require.NoError(t, err)
+
+			// connect request, the client sends IPv4 addresses
+			_, err = io.ReadFull(conn, buf[:4])
+			require.NoError(t, err)
+			require.Equal(t, byte(1), buf[3])
+			_, err = io.ReadFull(conn, buf[:6])
+			require.NoError(t, err)
+
+			requests.Add(1)
+
+			addr := net.JoinHostPort(net.IP(buf[:4]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(buf[4:6]))))
+			target, err := net.Dial("tcp", addr)
+			require.NoError(t, err)
+
+			_, err = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+			require.NoError(t, err)
+
+			pipe(conn, target)
+		}()
+	}
+}
+
+func TestProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer server.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer tlsServer.Close()
+
+	t.Run("Send requests through an authenticated HTTP proxy", func(t *testing.T) {
+		proxy := &testHTTPProxy{t: t}
+		proxyServer := httptest.NewServer(proxy)
+
+		defer proxyServer.Close()
+
+		proxyURL, err := url.Parse(proxyServer.URL)
+		require.NoError(t, err)
+		proxyURL.User = url.UserPassword("user", "pass")
+
+		testClient := client.New(client.WithProxy(proxyURL))
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(1), proxy.requests.Load())
+		assert.Equal(t, "Basic dXNlcjpwYXNz", proxy.authorization.Load())
+	})
+
+	t.Run("Tunnel https requests with CONNECT", func(t *testing.T) {
+		proxy := &testHTTPProxy{t: t}
+		proxyServer := httptest.NewServer(proxy)
+
+		defer proxyServer.Close()
+
+		proxyURL, err := url.Parse(proxyServer.URL)
+		require.NoError(t, err)
+
+		pool := tlsServer.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+		testClient := client.New(client.WithProxy(proxyURL), client.WithRootCAs(pool))
+
+		resp, err := testClient.Get(context.Background(), tlsServer.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(1), proxy.requests.Load())
+	})
+
+	t.Run("Send requests through an authenticated SOCKS5 proxy", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		defer l.Close()
+
+		var requests atomic.Int32
+		go serveSOCKS5(t, l, "user", "pass", &requests)
+
+		testClient := client.New(client.WithProxy(&url.URL{
+			Scheme: "socks5",
+			Host:   l.Addr().String(),
+			User:   url.UserPassword("user", "pass"),
+		}))
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("Select the proxy with the first matching rule", func(t *testing.T) {
+		proxy := &testHTTPProxy{t: t}
+		proxyServer := httptest.NewServer(proxy)
+
+		defer proxyServer.Close()
+
+		proxyURL, err := url.Parse(proxyServer.URL)
+		require.NoError(t, err)
+
+		testClient := client.New(
+			client.WithProxy(proxyURL),
+			client.WithProxyRules(
+				client.ProxyRule{Match: "*.internal"},
+				client.ProxyRule{Match: "127.0.0.0/8"},
+			),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, int32(0), proxy.requests.Load())
+
+		// hosts matching no rule go through the default proxy
+		_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+		require.NoError(t, err)
+
+		resp, err = testClient.Get(context.Background(), "http://localhost:"+port)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int32(1), proxy.requests.Load())
+	})
+}
@@ -0,0 +1,344 @@
+// Package replay sends batches of requests described in JSON lines through a client.Client, and writes
+// their results as JSON lines, in the order of the requests, so that the results of two runs can be diffed.
+package replay
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kamilsk/retry/v5/strategy"
+	"github.com/pkg/errors"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// Spec describes a request, one per line of the input of Run
+type Spec struct {
+	// ID identifies the request in the results, it defaults to the position of the spec in the input
+	ID     string      `json:"id,omitempty"`
+	Method string      `json:"method,omitempty"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// BodyEncoding is base64 when Body is base64 encoded
+	BodyEncoding string       `json:"body_encoding,omitempty"`
+	Options      *SpecOptions `json:"options,omitempty"`
+}
+
+// SpecOptions override the client options for a request, unset options keep the options of the client.
+// Timeout, Retries and Backoff replace the retry policy of the client as a whole, hence Timeout and Retries
+// are both required when any of them is set.
+type SpecOptions struct {
+	// Timeout is the timeout of each attempt, as "1.5s"
+	Timeout Duration `json:"timeout,omitempty"`
+	// Retries is the maximum number of attempts
+	Retries uint `json:"retries,omitempty"`
+	// Backoff is the back off between attempts: standard, none or a constant duration, standard by default
+	Backoff string `json:"backoff,omitempty"`
+	// MaxResponseBytes limits the size of the response body
+	MaxResponseBytes int64 `json:"max_response_bytes,omitempty"`
+}
+
+// Duration is a time.Duration encoded in JSON as a string such as "300ms"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Wrap(err, "duration must be a string")
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return errors.Wrap(err, "invalid duration")
+	}
+	*d = Duration(v)
+
+	return nil
+}
+
+// Result is the result of a request, one per line of the output of Run
+type Result struct {
+	// Index is the position of the spec of the request in the input, starting from 1
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Method   string `json:"method"`
+	URL      string `json:"url"`
+	Status   int    `json:"status,omitempty"`
+	Attempts int    `json:"attempts"`
+	// Bytes is the size of the response body
+	Bytes     int64   `json:"bytes"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Summary sums up a run
+type Summary struct {
+	Requests int
+	// Failed is the number of requests that got no response, or whose response couldn't be read
+	Failed int
+	// Statuses counts the responses by status
+	Statuses map[int]int
+}
+
+// Option configures Run
+type Option interface {
+	apply(*config)
+}
+
+type optionFunc func(*config)
+
+func (f optionFunc) apply(c *config) {
+	f(c)
+}
+
+type config struct {
+	concurrency int
+	rate        float64
+}
+
+// WithConcurrency sends up to n requests at once, requests are sent one at a time by default
+func WithConcurrency(n int) Option {
+	return optionFunc(func(c *config) {
+		c.concurrency = n
+	})
+}
+
+// WithRate sends at most perSecond requests per second, a rate of zero means no limit
+func WithRate(perSecond float64) Option {
+	return optionFunc(func(c *config) {
+		c.rate = perSecond
+	})
+}
+
+// Run sends the requests described by the specs read from specs through c, and writes their results to
+// results. Results are written in the order of the specs, whatever the concurrency. Run stops at the first
+// spec it cannot parse, once the requests before it are done.
+func Run(ctx context.Context, c *client.Client, specs io.Reader, results io.Writer, opts ...Option) (Summary, error) {
+	cfg := config{concurrency: 1}
+	for _, o := range opts {
+		o.apply(&cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+
+	var limit <-chan time.Time
+	if cfg.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.rate))
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+
+	// pending holds the results of the requests in flight, in the order of the specs, it bounds the
+	// results waiting for a slow request to be written
+	pending := make(chan chan Result, cfg.concurrency)
+	written := make(chan error, 1)
+	summary := Summary{Statuses: make(map[int]int)}
+
+	go func() {
+		enc := client.NewJSONLinesEncoder(results)
+
+		var err error
+		for result := range pending {
+			r := <-result
+			summary.add(r)
+			if err == nil {
+				err = errors.Wrap(enc.Encode(r), "error writing result")
+			}
+		}
+
+		written <- err
+	}()
+
+	var (
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, cfg.concurrency)
+		stream = client.StreamJSONLines[Spec](specs, 0)
+		index  int
+		err    error
+	)
+
+dispatch:
+	for stream.Next() {
+		index++
+		spec := stream.Value()
+
+		if limit != nil {
+			select {
+			case <-limit:
+			case <-ctx.Done():
+				err = ctx.Err()
+				break dispatch
+			}
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break dispatch
+		}
+
+		result := make(chan Result, 1)
+		pending <- result
+
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result <- send(ctx, c, index, spec)
+		}(index)
+	}
+	if err == nil {
+		err = errors.Wrap(stream.Err(), "error reading specs")
+	}
+
+	wg.Wait()
+	close(pending)
+	if wErr := <-written; err == nil {
+		err = wErr
+	}
+
+	return summary, err
+}
+
+func (s *Summary) add(r Result) {
+	s.Requests++
+	if r.Error != "" {
+		s.Failed++
+	}
+	if r.Status != 0 {
+		s.Statuses[r.Status]++
+	}
+}
+
+// send sends the request of spec
+func send(ctx context.Context, c *client.Client, index int, spec Spec) Result {
+	r := Result{
+		Index:  index,
+		ID:     spec.ID,
+		Method: spec.Method,
+		URL:    spec.URL,
+	}
+	if r.ID == "" {
+		r.ID = strconv.Itoa(index)
+	}
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+
+	req, opts, err := spec.request(ctx)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+
+	var attempts int
+	opts = append(opts, client.WithAttemptObserver(func(client.AttemptInfo) {
+		attempts++
+	}))
+
+	start := time.Now()
+	resp, err := c.Do(req, opts...)
+	if err == nil {
+		r.Status = resp.StatusCode
+		r.Bytes, err = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	r.LatencyMS = float64(time.Since(start)) / float64(time.Millisecond)
+	r.Attempts = attempts
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	return r
+}
+
+// request returns the request of the spec and its options
+func (s Spec) request(ctx context.Context) (*http.Request, []client.Option, error) {
+	method := s.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	switch s.BodyEncoding {
+	case "":
+		if s.Body != "" {
+			body = client.NewBytesSeekReader([]byte(s.Body))
+		}
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(s.Body)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error decoding body")
+		}
+		body = client.NewBytesSeekReader(b)
+	default:
+		return nil, nil, errors.Errorf("unknown body encoding %q", s.BodyEncoding)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.URL, body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error creating request")
+	}
+	for k, values := range s.Header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	opts, err := s.Options.options()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return req, opts, nil
+}
+
+// options returns the client options of a spec
+func (o *SpecOptions) options() ([]client.Option, error) {
+	if o == nil {
+		return nil, nil
+	}
+
+	var opts []client.Option
+	if o.Timeout != 0 || o.Retries != 0 || o.Backoff != "" {
+		if o.Timeout == 0 || o.Retries == 0 {
+			return nil, errors.New("invalid options: timeout and retries are both required to set the retry policy")
+		}
+		timeout := time.Duration(o.Timeout)
+
+		switch o.Backoff {
+		case "", "standard":
+			opts = append(opts, client.WithStandardRetryPolicy(timeout, o.Retries))
+		case "none":
+			opts = append(opts, client.WithRetryPolicy(timeout, o.Retries))
+		default:
+			d, err := time.ParseDuration(o.Backoff)
+			if err != nil {
+				return nil, errors.Errorf("invalid backoff %q: expected standard, none or a duration", o.Backoff)
+			}
+			opts = append(opts, client.WithRetryPolicy(timeout, o.Retries, strategy.Wait(d)))
+		}
+	}
+
+	if o.MaxResponseBytes > 0 {
+		opts = append(opts, client.WithMaxResponseBytes(o.MaxResponseBytes))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,180 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+	"github.com/zackwwu/http-client-go/replay"
+)
+
+func newServer(t *testing.T) *httptest.Server {
+	var flaky int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		case "/flaky":
+			// the first two requests fail
+			if atomic.AddInt32(&flaky, 1) <= 2 {
+				conn, _, err := w.(http.Hijacker).Hijack()
+				require.NoError(t, err)
+				conn.Close()
+
+				return
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, _ = w.Write([]byte(r.Method + " " + r.Header.Get("X-Test") + " " + string(body)))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func decodeResults(t *testing.T, output []byte) []replay.Result {
+	stream := client.StreamJSONLines[replay.Result](bytes.NewReader(output), 0)
+
+	var results []replay.Result
+	for stream.Next() {
+		results = append(results, stream.Value())
+	}
+	require.NoError(t, stream.Err())
+
+	return results
+}
+
+func TestRun(t *testing.T) {
+	testClient := client.New(client.WithRetryPolicy(time.Second, 1))
+
+	t.Run("Write results in the order of the specs", func(t *testing.T) {
+		server := newServer(t)
+
+		specs := strings.Join([]string{
+			`{"id":"slow","url":"` + server.URL + `/slow"}`,
+			``,
+			`{"method":"POST","url":"` + server.URL + `/items","header":{"X-Test":["a"]},"body":"hello"}`,
+			`{"url":"` + server.URL + `/missing"}`,
+			`{"method":"PUT","url":"` + server.URL + `/items","body":"AP8=","body_encoding":"base64"}`,
+		}, "\n")
+
+		var output bytes.Buffer
+		summary, err := replay.Run(context.Background(), testClient, strings.NewReader(specs), &output, replay.WithConcurrency(4))
+		require.NoError(t, err)
+
+		results := decodeResults(t, output.Bytes())
+		require.Len(t, results, 4)
+
+		assert.Equal(t, "slow", results[0].ID)
+		assert.Equal(t, http.StatusOK, results[0].Status)
+		assert.GreaterOrEqual(t, results[0].LatencyMS, 100.0)
+
+		assert.Equal(t, 2, results[1].Index)
+		assert.Equal(t, "2", results[1].ID)
+		assert.Equal(t, http.MethodPost, results[1].Method)
+		assert.EqualValues(t, len("POST a hello"), results[1].Bytes)
+		assert.Equal(t, 1, results[1].Attempts)
+
+		assert.Equal(t, http.MethodGet, results[2].Method)
+		assert.Equal(t, http.StatusNotFound, results[2].Status)
+		assert.Empty(t, results[2].Error)
+
+		assert.EqualValues(t, len("PUT  \x00\xff"), results[3].Bytes)
+
+		assert.Equal(t, 4, summary.Requests)
+		assert.Zero(t, summary.Failed)
+		assert.Equal(t, map[int]int{200: 3, 404: 1}, summary.Statuses)
+	})
+
+	t.Run("Apply the options of the specs", func(t *testing.T) {
+		server := newServer(t)
+
+		specs := `{"url":"` + server.URL + `/flaky"}` + "\n" +
+			`{"url":"` + server.URL + `/flaky","options":{"retries":3,"backoff":"none","timeout":"1s"}}` + "\n" +
+			`{"url":"` + server.URL + `/items","options":{"backoff":"sometimes","timeout":"1s","retries":2}}` + "\n" +
+			`{"url":"` + server.URL + `/items","options":{"retries":3}}` + "\n" +
+			`{"url":"` + server.URL + `/items","options":{"max_response_bytes":4}}` + "\n" +
+			`{"url":"::"}` + "\n"
+
+		var output bytes.Buffer
+		summary, err := replay.Run(context.Background(), testClient, strings.NewReader(specs), &output)
+		require.NoError(t, err)
+
+		results := decodeResults(t, output.Bytes())
+		require.Len(t, results, 6)
+
+		assert.NotEmpty(t, results[0].Error)
+		assert.Equal(t, 1, results[0].Attempts)
+
+		assert.Empty(t, results[1].Error)
+		assert.Equal(t, 2, results[1].Attempts)
+
+		assert.Contains(t, results[2].Error, "invalid backoff")
+		// a partial retry policy is rejected rather than completed with defaults
+		assert.Contains(t, results[3].Error, "timeout and retries are both required")
+		assert.Contains(t, results[4].Error, client.ErrResponseTooLarge.Error())
+		assert.Contains(t, results[5].Error, "error creating request")
+
+		assert.Equal(t, 5, summary.Failed)
+	})
+
+	t.Run("Keep the attempt observers of the client", func(t *testing.T) {
+		server := newServer(t)
+
+		var observed atomic.Int32
+		observedClient := client.New(client.WithRetryPolicy(time.Second, 3), client.WithAttemptObserver(func(client.AttemptInfo) {
+			observed.Add(1)
+		}))
+
+		var output bytes.Buffer
+		_, err := replay.Run(context.Background(), observedClient, strings.NewReader(`{"url":"`+server.URL+`/flaky"}`+"\n"), &output)
+		require.NoError(t, err)
+
+		results := decodeResults(t, output.Bytes())
+		require.Len(t, results, 1)
+		assert.Equal(t, 3, results[0].Attempts)
+		assert.EqualValues(t, 3, observed.Load())
+	})
+
+	t.Run("Limit the rate of requests", func(t *testing.T) {
+		server := newServer(t)
+
+		specs := strings.Repeat(`{"url":"`+server.URL+`"}`+"\n", 3)
+
+		start := time.Now()
+		summary, err := replay.Run(context.Background(), testClient, strings.NewReader(specs), io.Discard,
+			replay.WithConcurrency(3), replay.WithRate(20))
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, summary.Requests)
+		assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	})
+
+	t.Run("Stop at invalid specs", func(t *testing.T) {
+		server := newServer(t)
+
+		specs := `{"url":"` + server.URL + `"}` + "\n" + `{"url":` + "\n" + `{"url":"` + server.URL + `"}` + "\n"
+
+		var output bytes.Buffer
+		summary, err := replay.Run(context.Background(), testClient, strings.NewReader(specs), &output)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "JSON line 2")
+
+		assert.Len(t, decodeResults(t, output.Bytes()), 1)
+		assert.Equal(t, 1, summary.Requests)
+	})
+}
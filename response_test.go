@@ -0,0 +1,131 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	content := `"` + strings.Repeat("0123456789", 100) + `"`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(content))
+		require.NoError(t, err)
+	}))
+
+	defer server.Close()
+
+	t.Run("Fail reading bodies larger than the limit", func(t *testing.T) {
+		testClient := client.New(client.WithMaxResponseBytes(int64(len(content) - 1)))
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		var v interface{}
+		err = json.NewDecoder(resp.Body).Decode(&v)
+		assert.ErrorIs(t, err, client.ErrResponseTooLarge)
+	})
+
+	t.Run("Read bodies up to the limit", func(t *testing.T) {
+		testClient := client.New()
+
+		resp, err := testClient.Get(context.Background(), server.URL, client.WithMaxResponseBytes(int64(len(content))))
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(body))
+	})
+}
+
+// newDrainServer starts a server sending the first half of its bodies right away, it returns the server
+// and the number of connections it accepted
+func newDrainServer(secondHalf func(r *http.Request) bool) (*httptest.Server, *atomic.Int32) {
+	var conns atomic.Int32
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("0123456789", 100)))
+		w.(http.Flusher).Flush()
+
+		if secondHalf(r) {
+			_, _ = w.Write([]byte(strings.Repeat("0123456789", 100)))
+		}
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+
+	return server, &conns
+}
+
+func TestResponseBodyDraining(t *testing.T) {
+	t.Run("Reuse the connection of bodies closed unread", func(t *testing.T) {
+		// the end of the body is sent shortly, so that it is left unread when the body is closed
+		server, conns := newDrainServer(func(*http.Request) bool {
+			time.Sleep(10 * time.Millisecond)
+			return true
+		})
+
+		defer server.Close()
+
+		testClient := client.New()
+
+		for i := 0; i < 5; i++ {
+			resp, err := testClient.Get(context.Background(), server.URL)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		assert.Equal(t, int32(1), conns.Load())
+	})
+
+	t.Run("Don't wait for the end of slow bodies", func(t *testing.T) {
+		// the end of the body is held back until the client disconnects
+		server, conns := newDrainServer(func(r *http.Request) bool {
+			select {
+			case <-r.Context().Done():
+				return false
+			case <-time.After(3 * time.Second):
+				return true
+			}
+		})
+
+		defer server.Close()
+
+		// without attempt timeout, closing the body is the only way to abort it
+		testClient := client.New(client.WithRetryPolicy(0, 1))
+
+		for i := 0; i < 2; i++ {
+			resp, err := testClient.Get(context.Background(), server.URL)
+			require.NoError(t, err)
+
+			_, err = io.ReadFull(resp.Body, make([]byte, 10))
+			require.NoError(t, err)
+
+			start := time.Now()
+			resp.Body.Close()
+			assert.Less(t, time.Since(start), time.Second)
+		}
+
+		assert.Equal(t, int32(2), conns.Load())
+	})
+}
@@ -0,0 +1,413 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kamilsk/retry/v5"
+	"github.com/kamilsk/retry/v5/strategy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxEventLineSize is the longest line of an event stream the client accepts
+	maxEventLineSize = 1 << 20
+	// defaultReconnectDelay is the reconnection time of a stream until the server sets one
+	defaultReconnectDelay = time.Second
+	// defaultStreamIdleTimeout is the idle timeout of streams, long enough for the keep-alives of most servers
+	defaultStreamIdleTimeout = 2 * time.Minute
+)
+
+// errStreamDisconnected ends a stream once the retry strategies stop its reconnections
+var errStreamDisconnected = errors.New("event stream disconnected")
+
+// Event is an event received from a Server-Sent Events stream
+type Event struct {
+	// ID is the last event ID of the stream when the event was dispatched
+	ID string
+	// Type is the type of the event, "message" unless the event sets it
+	Type string
+	// Data is the data of the event, the lines of multi-line data are joined with "\n"
+	Data string
+	// Retry is the reconnection time the event set, zero if it set none
+	Retry time.Duration
+}
+
+// EventStream reads the events of a Server-Sent Events stream, reconnecting whenever the stream is
+// disconnected. It is not safe for concurrent use.
+type EventStream struct {
+	client      *Client
+	url         string
+	opts        []Option
+	strategies  []strategy.Strategy
+	idleTimeout time.Duration
+	clock       Clock
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	body           *idleReadCloser
+	scanner        *bufio.Scanner
+	lastEventID    string
+	reconnectDelay time.Duration
+	disconnects    int
+
+	event Event
+	err   error
+}
+
+// Events connects to the Server-Sent Events stream at url. Whenever the stream is disconnected, it is
+// reconnected with the Last-Event-ID of the last event received, after the reconnection time set by the
+// server, one second until it sets one. Each disconnection since the last event received counts as a
+// failed attempt of the retry strategies of the client, which delay the reconnection further or end the
+// stream, and failed reconnections are retried as they direct. The stream ends once the server answers
+// a reconnection with 204 No Content.
+//
+// The request timeout of the retry policy doesn't apply to streams, which are long-lived, a stream not
+// receiving any data for the duration set by WithStreamIdleTimeout is reconnected instead.
+func (c *Client) Events(ctx context.Context, url string, opts ...Option) (*EventStream, error) {
+	requestOpts := c.options
+	for _, o := range opts {
+		o.apply(&requestOpts, c.generator)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &EventStream{
+		client:      c,
+		url:         url,
+		opts:        append(opts[:len(opts):len(opts)], withStreamingAttempt()),
+		strategies:  requestOpts.retryPolicy.strategies(clockOf(requestOpts)),
+		idleTimeout: streamIdleTimeout(requestOpts),
+		clock:       clockOf(requestOpts),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	if err := s.connect(); err == io.EOF {
+		// the server has no events to send
+		s.err = err
+	} else if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// streamIdleTimeout returns the idle timeout of streams, which defaults to defaultStreamIdleTimeout
+func streamIdleTimeout(opts options) time.Duration {
+	if opts.streamIdleTimeout != 0 {
+		return opts.streamIdleTimeout
+	}
+
+	return defaultStreamIdleTimeout
+}
+
+// withStreamingAttempt disables the retries and the timeout of a request, streams handle both themselves
+func withStreamingAttempt() Option {
+	return newFuncOption(func(o *options, g *rand.Rand) {
+		o.retryPolicy = &retryPolicy{
+			retryStrategies: []strategy.Strategy{strategy.Limit(1)},
+		}
+	})
+}
+
+// Next reads the next event of the stream, it returns false once the stream ended or failed, Err tells
+// which one
+func (s *EventStream) Next() bool {
+	for s.err == nil {
+		if s.scanner == nil {
+			if err := s.wait(); err != nil {
+				s.err = err
+				return false
+			}
+
+			if err := s.connect(); err != nil {
+				s.err = err
+				return false
+			}
+		}
+
+		if s.readEvent() {
+			s.disconnects = 0
+			return true
+		}
+
+		// the stream was disconnected
+		_ = s.body.Close()
+		s.body, s.scanner = nil, nil
+		s.disconnects++
+
+		if err := s.ctx.Err(); err != nil {
+			s.err = err
+		}
+	}
+
+	return false
+}
+
+// wait waits before reconnecting a disconnected stream for the reconnection time, then as directed by the
+// retry strategies, which get the number of disconnections since the last event as the attempt
+func (s *EventStream) wait() error {
+	delay := s.reconnectDelay
+	if delay == 0 {
+		delay = defaultReconnectDelay
+	}
+
+	timer := s.clock.NewTimer(delay)
+	select {
+	case <-timer.C():
+	case <-s.ctx.Done():
+		timer.Stop()
+		return s.ctx.Err()
+	}
+
+	for _, next := range s.strategies {
+		if !next(s.ctx, uint(s.disconnects), errStreamDisconnected) {
+			if err := s.ctx.Err(); err != nil {
+				return err
+			}
+			return errStreamDisconnected
+		}
+	}
+
+	return nil
+}
+
+// Event returns the event read by the last call to Next
+func (s *EventStream) Event() Event {
+	return s.event
+}
+
+// Err returns the error that ended the stream, nil if the server ended it
+func (s *EventStream) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+
+	return s.err
+}
+
+// Close disconnects the stream
+func (s *EventStream) Close() error {
+	s.cancel()
+	if s.err == nil {
+		s.err = context.Canceled
+	}
+
+	if s.body != nil {
+		err := s.body.Close()
+		s.body, s.scanner = nil, nil
+
+		return err
+	}
+
+	return nil
+}
+
+// connect sends the request of the stream, retrying it as directed by the retry strategies of the client
+func (s *EventStream) connect() error {
+	action := func(ctx context.Context) error {
+		connCtx, cancel := context.WithCancel(ctx)
+
+		req, err := http.NewRequestWithContext(connCtx, http.MethodGet, s.url, nil)
+		if err != nil {
+			cancel()
+			return &permanentError{errors.Wrap(err, "error creating request")}
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Cache-Control", "no-cache")
+		if s.lastEventID != "" {
+			req.Header.Set("Last-Event-ID", s.lastEventID)
+		}
+
+		resp, err := s.client.Do(req, s.opts...)
+		if err != nil {
+			cancel()
+			return markPermanent(err)
+		}
+
+		if err := checkEventStreamResponse(resp); err != nil {
+			drainAndClose(resp)
+			cancel()
+			return err
+		}
+
+		s.body = newIdleReadCloser(resp.Body, s.clock, s.idleTimeout, cancel)
+		s.scanner = bufio.NewScanner(s.body)
+		s.scanner.Buffer(make([]byte, 0, 4096), maxEventLineSize)
+		s.scanner.Split(scanEventLines)
+
+		return nil
+	}
+
+	return unwrapPermanent(retry.Do(s.ctx, action, s.strategies...))
+}
+
+func checkEventStreamResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		// the server asks the client to stop reconnecting
+		return &permanentError{io.EOF}
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		return errors.Errorf("unexpected status %q", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return &permanentError{errors.Errorf("unexpected status %q", resp.Status)}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/event-stream" {
+		return &permanentError{errors.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))}
+	}
+
+	return nil
+}
+
+// readEvent reads lines until an event is dispatched, it returns false once the stream is disconnected,
+// discarding any incomplete event
+func (s *EventStream) readEvent() bool {
+	var (
+		eventType string
+		data      strings.Builder
+		hasData   bool
+		retryTime time.Duration
+	)
+
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+
+		if line == "" {
+			if !hasData {
+				eventType = ""
+				continue
+			}
+
+			if eventType == "" {
+				eventType = "message"
+			}
+			s.event = Event{
+				ID:    s.lastEventID,
+				Type:  eventType,
+				Data:  strings.TrimSuffix(data.String(), "\n"),
+				Retry: retryTime,
+			}
+
+			return true
+		}
+
+		if strings.HasPrefix(line, ":") {
+			// comment, e.g. a keep-alive
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retryTime = time.Duration(ms) * time.Millisecond
+				s.reconnectDelay = retryTime
+			}
+		}
+	}
+
+	// errors reading the body disconnect the stream, while errors of the scanner itself, such as a line
+	// longer than maxEventLineSize, would happen again on every reconnection
+	if err := s.scanner.Err(); err != nil && err != s.body.err {
+		s.err = errors.Wrap(err, "error reading event stream")
+	}
+
+	return false
+}
+
+// scanEventLines splits an event stream into lines ended by CRLF, LF or CR
+func scanEventLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+
+		// a CR may be followed by a LF which isn't read yet
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+
+		return 0, nil, nil
+	}
+
+	// an unterminated line at the end of the stream is discarded
+	if atEOF {
+		return len(data), nil, nil
+	}
+
+	return 0, nil, nil
+}
+
+// idleReadCloser cancels a stream once no data was read for the idle timeout
+type idleReadCloser struct {
+	readCloser io.ReadCloser
+	timeout    time.Duration
+	timer      Timer
+	cancel     context.CancelFunc
+	// err is the last error reading the stream
+	err error
+}
+
+func newIdleReadCloser(rc io.ReadCloser, clock Clock, timeout time.Duration, cancel context.CancelFunc) *idleReadCloser {
+	irc := &idleReadCloser{
+		readCloser: rc,
+		timeout:    timeout,
+		cancel:     cancel,
+	}
+	if timeout > 0 {
+		irc.timer = clock.AfterFunc(timeout, cancel)
+	}
+
+	return irc
+}
+
+func (rc *idleReadCloser) Read(p []byte) (int, error) {
+	n, err := rc.readCloser.Read(p)
+	if n > 0 && rc.timer != nil {
+		rc.timer.Reset(rc.timeout)
+	}
+	if err != nil {
+		rc.err = err
+	}
+
+	return n, err
+}
+
+func (rc *idleReadCloser) Close() error {
+	if rc.timer != nil {
+		rc.timer.Stop()
+	}
+	defer rc.cancel()
+
+	return rc.readCloser.Close()
+}
@@ -0,0 +1,208 @@
+package client_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+// newEventServer starts a server sending streams[i] as the event stream of the i-th connection, the
+// server answers 204 No Content once there are no streams left. A stream ending with hang keeps the
+// connection open until the client disconnects.
+func newEventServer(t *testing.T, streams ...string) (*httptest.Server, func() []string) {
+	var (
+		mu           sync.Mutex
+		lastEventIDs []string
+	)
+
+	const hang = "hang"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+
+		mu.Lock()
+		conn := len(lastEventIDs)
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+
+		if conn >= len(streams) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		stream := streams[conn]
+		if len(stream) >= len(hang) && stream[len(stream)-len(hang):] == hang {
+			stream = stream[:len(stream)-len(hang)]
+			defer func() { <-r.Context().Done() }()
+		}
+
+		_, err := io.WriteString(w, stream)
+		require.NoError(t, err)
+		w.(http.Flusher).Flush()
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), lastEventIDs...)
+	}
+}
+
+func readEvents(t *testing.T, stream *client.EventStream) []client.Event {
+	var events []client.Event
+	for stream.Next() {
+		events = append(events, stream.Event())
+	}
+	require.NoError(t, stream.Err())
+
+	return events
+}
+
+func TestEvents(t *testing.T) {
+	t.Run("Parse events and reconnect with the last event ID", func(t *testing.T) {
+		server, lastEventIDs := newEventServer(t,
+			": keep-alive\n\nid: 1\ndata: first\ndata: line\n\nevent: update\r\nid: 2\r\ndata:{\"a\":1}\r\n\r\n",
+			"retry: 10\nid: 3\ndata: third\n\ndata: incomplete",
+		)
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		stream, err := testClient.Events(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer stream.Close()
+
+		assert.Equal(t, []client.Event{
+			{ID: "1", Type: "message", Data: "first\nline"},
+			{ID: "2", Type: "update", Data: `{"a":1}`},
+			{ID: "3", Type: "message", Data: "third", Retry: 10 * time.Millisecond},
+		}, readEvents(t, stream))
+		assert.Equal(t, []string{"", "2", "3"}, lastEventIDs())
+	})
+
+	t.Run("Reconnect idle streams", func(t *testing.T) {
+		server, lastEventIDs := newEventServer(t, "retry: 10\nid: 1\ndata: first\n\nhang", "id: 2\ndata: second\n\n")
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		stream, err := testClient.Events(context.Background(), server.URL, client.WithStreamIdleTimeout(100*time.Millisecond))
+		require.NoError(t, err)
+
+		defer stream.Close()
+
+		assert.Equal(t, []client.Event{
+			{ID: "1", Type: "message", Data: "first", Retry: 10 * time.Millisecond},
+			{ID: "2", Type: "message", Data: "second"},
+		}, readEvents(t, stream))
+		assert.Equal(t, []string{"", "1", "2"}, lastEventIDs())
+	})
+
+	t.Run("Wait before reconnecting streams closed without events", func(t *testing.T) {
+		var conns atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conns.Add(1)
+			w.Header().Set("Content-Type", "text/event-stream")
+		}))
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+
+		stream, err := testClient.Events(ctx, server.URL)
+		require.NoError(t, err)
+
+		defer stream.Close()
+
+		assert.False(t, stream.Next())
+		assert.ErrorIs(t, stream.Err(), context.DeadlineExceeded)
+		assert.Equal(t, int32(1), conns.Load())
+	})
+
+	t.Run("Stop reconnecting once the retry strategies give up", func(t *testing.T) {
+		server, lastEventIDs := newEventServer(t, "retry: 10\nid: 1\ndata: first\n\n", "", "", "", "")
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		stream, err := testClient.Events(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer stream.Close()
+
+		require.True(t, stream.Next())
+		assert.False(t, stream.Next())
+		assert.EqualError(t, stream.Err(), "event stream disconnected")
+		assert.Equal(t, []string{"", "1", "1"}, lastEventIDs())
+	})
+
+	t.Run("Fail on lines longer than the limit", func(t *testing.T) {
+		server, lastEventIDs := newEventServer(t, "data: "+strings.Repeat("a", 1<<20)+"\n\n")
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		stream, err := testClient.Events(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer stream.Close()
+
+		assert.False(t, stream.Next())
+		assert.ErrorIs(t, stream.Err(), bufio.ErrTooLong)
+		assert.Equal(t, []string{""}, lastEventIDs())
+	})
+
+	t.Run("Streams outlive the request timeout", func(t *testing.T) {
+		server, lastEventIDs := newEventServer(t, "id: 1\ndata: first\n\nhang")
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(50*time.Millisecond, 3))
+
+		stream, err := testClient.Events(context.Background(), server.URL, client.WithStreamIdleTimeout(time.Minute))
+		require.NoError(t, err)
+
+		require.True(t, stream.Next())
+		assert.Equal(t, "first", stream.Event().Data)
+
+		time.Sleep(200 * time.Millisecond)
+		require.NoError(t, stream.Close())
+
+		assert.False(t, stream.Next())
+		assert.ErrorIs(t, stream.Err(), context.Canceled)
+		assert.Equal(t, []string{""}, lastEventIDs())
+	})
+
+	t.Run("Fail on responses other than event streams", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+
+		defer server.Close()
+
+		testClient := client.New(client.WithRetryPolicy(time.Second, 3))
+
+		stream, err := testClient.Events(context.Background(), server.URL)
+		assert.Error(t, err)
+		assert.Nil(t, stream)
+	})
+}
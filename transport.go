@@ -0,0 +1,91 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+)
+
+// transportOptions configures the http.Transport of a Client, unlike other options, transport
+// options only take effect when passed to New()
+type transportOptions struct {
+	clientCertificates []tls.Certificate
+	certReloader       *CertReloader
+	rootCAs            *x509.CertPool
+	minTLSVersion      uint16
+	serverName         string
+	pinSet             *PinSet
+	pinReport          func(*PinViolationError)
+	proxy              *url.URL
+	proxyRules         []ProxyRule
+	dialContext        DialContextFunc
+	hostDialContexts   map[string]DialContextFunc
+	roundTripper       http.RoundTripper
+}
+
+func (t *transportOptions) setHostDialContext(host string, dial DialContextFunc) {
+	hostDialContexts := make(map[string]DialContextFunc, len(t.hostDialContexts)+1)
+	for h, d := range t.hostDialContexts {
+		hostDialContexts[h] = d
+	}
+	hostDialContexts[host] = dial
+
+	t.hostDialContexts = hostDialContexts
+}
+
+// transport returns the transport options for modification
+func (o *options) transport() *transportOptions {
+	return copyOnWrite(&o.transportOptions)
+}
+
+// newHTTPClient returns http.DefaultClient unless transport options are specified, in which case
+// a dedicated http.Client is created on top of a clone of http.DefaultTransport, or on top of the
+// RoundTripper given by WithTransport
+func newHTTPClient(opts *transportOptions) *http.Client {
+	if opts == nil {
+		return http.DefaultClient
+	}
+
+	if opts.roundTripper != nil {
+		return &http.Client{Transport: opts.roundTripper}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = newTLSConfig(opts)
+
+	if opts.dialContext != nil || len(opts.hostDialContexts) > 0 {
+		transport.DialContext = newDialContext(transport.DialContext, opts.dialContext, opts.hostDialContexts)
+	}
+
+	if opts.proxy != nil || len(opts.proxyRules) > 0 {
+		transport.Proxy = newProxyFunc(opts.proxy, opts.proxyRules)
+	}
+
+	return &http.Client{Transport: transport}
+}
+
+func newTLSConfig(opts *transportOptions) *tls.Config {
+	tlsConfig := &tls.Config{
+		Certificates: opts.clientCertificates,
+		RootCAs:      opts.rootCAs,
+		MinVersion:   opts.minTLSVersion,
+		ServerName:   opts.serverName,
+	}
+
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+
+	if opts.certReloader != nil {
+		// the reloader is consulted on every handshake, so rotated certificates are picked up by
+		// new connections while established connections keep serving in-flight requests
+		tlsConfig.GetClientCertificate = opts.certReloader.GetClientCertificate
+	}
+
+	if opts.pinSet != nil {
+		tlsConfig.VerifyConnection = opts.pinSet.verifyConnection(opts.pinReport)
+	}
+
+	return tlsConfig
+}
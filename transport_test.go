@@ -0,0 +1,211 @@
+package client_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	client "github.com/zackwwu/http-client-go"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue returns the PEM encoded certificate and key of a new client certificate
+func (ca *testCA) issue(t *testing.T, serial int64) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// newMutualTLSServer starts a TLS server requiring client certificates issued by ca, the server
+// records the serial number of the last client certificate and the requested server name
+func newMutualTLSServer(ca *testCA, lastSerial *atomic.Int64, serverName *atomic.Value) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastSerial.Store(r.TLS.PeerCertificates[0].SerialNumber.Int64())
+		serverName.Store(r.TLS.ServerName)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	server.TLS = &tls.Config{
+		ClientCAs:  ca.pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}
+	server.StartTLS()
+
+	return server
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+
+	var (
+		lastSerial atomic.Int64
+		serverName atomic.Value
+	)
+
+	server := newMutualTLSServer(ca, &lastSerial, &serverName)
+
+	defer server.Close()
+
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(server.Certificate())
+
+	t.Run("Present the client certificate and verify the server with custom root CAs", func(t *testing.T) {
+		certPEM, keyPEM := ca.issue(t, 10)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		require.NoError(t, err)
+
+		testClient := client.New(
+			client.WithClientCertificates(cert),
+			client.WithRootCAs(serverCAs),
+			client.WithMinTLSVersion(tls.VersionTLS13),
+			client.WithServerName("example.com"),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+		assert.Equal(t, int64(10), lastSerial.Load())
+		assert.Equal(t, "example.com", serverName.Load())
+	})
+
+	t.Run("Fail the handshake without client certificate", func(t *testing.T) {
+		testClient := client.New(
+			client.WithRootCAs(serverCAs),
+			client.WithRetryPolicy(time.Second, 1),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL) //nolint: bodyclose
+		assert.Error(t, err)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("Rotated certificates are used without recreating the client", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile := filepath.Join(dir, "client.crt")
+		keyFile := filepath.Join(dir, "client.key")
+
+		writeKeyPair := func(serial int64) {
+			certPEM, keyPEM := ca.issue(t, serial)
+			require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+			require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+		}
+
+		writeKeyPair(20)
+
+		reloader, err := client.NewCertReloader(certFile, keyFile, 10*time.Millisecond, nil)
+		require.NoError(t, err)
+
+		defer reloader.Close()
+
+		testClient := client.New(
+			client.WithCertReloader(reloader),
+			client.WithRootCAs(serverCAs),
+		)
+
+		resp, err := testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, int64(20), lastSerial.Load())
+
+		// make sure the modification time changes on file systems with coarse timestamps
+		writeKeyPair(21)
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(certFile, future, future))
+		require.NoError(t, os.Chtimes(keyFile, future, future))
+
+		require.Eventually(t, func() bool {
+			cert, err := reloader.GetClientCertificate(nil)
+			require.NoError(t, err)
+
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			require.NoError(t, err)
+
+			return leaf.SerialNumber.Int64() == 21
+		}, time.Second, 10*time.Millisecond)
+
+		// the established connection keeps serving until it is closed, new connections present the
+		// rotated certificate
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		req.Close = true
+
+		resp, err = testClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		resp, err = testClient.Get(context.Background(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, int64(21), lastSerial.Load())
+	})
+}